@@ -0,0 +1,234 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func getClusterSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				// changing the target cluster re-creates the object
+				// in the new cluster, there is nothing to patch there
+				"kubeconfig_raw": &schema.Schema{
+					Type:          schema.TypeString,
+					Optional:      true,
+					Sensitive:     true,
+					ForceNew:      true,
+					ConflictsWith: []string{"cluster.0.host"},
+				},
+				"context": &schema.Schema{
+					Type:          schema.TypeString,
+					Optional:      true,
+					ForceNew:      true,
+					ConflictsWith: []string{"cluster.0.host"},
+				},
+				"host": &schema.Schema{
+					Type:          schema.TypeString,
+					Optional:      true,
+					ForceNew:      true,
+					ConflictsWith: []string{"cluster.0.kubeconfig_raw"},
+				},
+				// credentials can change without changing the
+				// cluster, e.g. rotating tokens, and only update the state
+				"token": &schema.Schema{
+					Type:         schema.TypeString,
+					Optional:     true,
+					Sensitive:    true,
+					RequiredWith: []string{"cluster.0.host"},
+				},
+				"cluster_ca_certificate": &schema.Schema{
+					Type:         schema.TypeString,
+					Optional:     true,
+					RequiredWith: []string{"cluster.0.host"},
+				},
+			},
+		},
+	}
+}
+
+// clusterClients are the client and mapper for one cluster
+type clusterClients struct {
+	client    dynamic.Interface
+	mapper    *restmapper.DeferredDiscoveryRESTMapper
+	discovery *discoveryCache
+	readCache *readCache
+	openAPI   *openAPISchemas
+	crds      *crdSchemas
+}
+
+// clusterCache caches the clients of the clusters configured
+// on resources, so resources targeting the same cluster share
+// one client and one mapper and its discovery cache
+type clusterCache struct {
+	mu       sync.Mutex
+	clusters map[string]*clusterClients
+}
+
+func newClusterCache() *clusterCache {
+	return &clusterCache{clusters: make(map[string]*clusterClients)}
+}
+
+func (cc *clusterCache) get(key string, config *rest.Config, discoveryCacheDir string, discoveryCacheTTL time.Duration) (*clusterClients, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if c, ok := cc.clusters[key]; ok {
+		return c, nil
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := newDiscoveryCache(dc, getDiscoveryCacheDir(discoveryCacheDir, config.Host), discoveryCacheTTL)
+	c := &clusterClients{
+		client:    client,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(cache),
+		discovery: cache,
+		readCache: newReadCache(),
+		openAPI:   newOpenAPISchemas(dc.OpenAPIV3()),
+		crds:      newCRDSchemas(),
+	}
+	cc.clusters[key] = c
+
+	return c, nil
+}
+
+func getClusterKey(in map[string]interface{}) string {
+	h := sha256.New()
+	for _, k := range []string{"kubeconfig_raw", "context", "host", "token", "cluster_ca_certificate"} {
+		h.Write([]byte(in[k].(string)))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func getClusterRestConfig(in map[string]interface{}) (*rest.Config, error) {
+	raw := in["kubeconfig_raw"].(string)
+	if raw != "" {
+		kubeconfig, err := clientcmd.Load([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("cluster: kubeconfig_raw: %s", err)
+		}
+
+		config, _, err := getClientConfig(kubeconfig, &clientcmd.ConfigOverrides{CurrentContext: in["context"].(string)})
+		if err != nil {
+			return nil, fmt.Errorf("cluster: kubeconfig_raw: %s", err)
+		}
+
+		return config, nil
+	}
+
+	host := in["host"].(string)
+	if host == "" {
+		return nil, fmt.Errorf("cluster: one of kubeconfig_raw or host must be set")
+	}
+
+	return &rest.Config{
+		Host:        host,
+		BearerToken: in["token"].(string),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: []byte(in["cluster_ca_certificate"].(string)),
+		},
+	}, nil
+}
+
+// getResourceConfig returns m unchanged, if cluster is not set.
+// Otherwise, it returns a copy of m using the client and mapper
+// for the configured cluster.
+func getResourceConfig(cluster interface{}, m interface{}) (interface{}, error) {
+	l := cluster.([]interface{})
+	if len(l) == 0 || l[0] == nil {
+		return m, nil
+	}
+
+	in := l[0].(map[string]interface{})
+	c := m.(*Config)
+
+	config, err := getClusterRestConfig(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the client settings of the provider
+	config.QPS = c.RestConfig.QPS
+	config.Burst = c.RestConfig.Burst
+	config.RateLimiter = newLoggingRateLimiter(config.QPS, config.Burst)
+	config.Timeout = c.RestConfig.Timeout
+	config.WrapTransport = c.RestConfig.WrapTransport
+	config.WarningHandler = c.RestConfig.WarningHandler
+
+	// the provider's proxy and impersonation also apply, unless the
+	// kubeconfig sets its own, so restricted setups stay restricted.
+	// The tls_server_name is for the provider's host, not this one.
+	if config.Proxy == nil {
+		config.Proxy = c.RestConfig.Proxy
+	}
+	if config.Impersonate.UserName == "" && config.Impersonate.UID == "" && len(config.Impersonate.Groups) == 0 {
+		config.Impersonate = c.RestConfig.Impersonate
+	}
+
+	key := getClusterKey(in)
+	var discoveryCacheDir string
+	var discoveryCacheTTL time.Duration
+	if c.Discovery != nil && c.Discovery.dir != "" {
+		// same parent directory as the provider's cluster
+		discoveryCacheDir = filepath.Dir(c.Discovery.dir)
+		discoveryCacheTTL = c.Discovery.ttl
+	}
+
+	clients, err := c.Clusters.get(key, config, discoveryCacheDir, discoveryCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: %s", err)
+	}
+
+	rc := *c
+	rc.RestConfig = config
+	rc.Client = clients.client
+	rc.Mapper = clients.mapper
+	rc.Discovery = clients.discovery
+	rc.OpenAPI = clients.openAPI
+	rc.CRDSchemas = clients.crds
+	if c.ReadCache != nil {
+		rc.ReadCache = clients.readCache
+	}
+	// namespace defaults of the provider don't apply to other clusters
+	rc.Namespace = ""
+
+	return &rc, nil
+}
+
+// isDeferred returns true, if the provider configuration is not
+// known yet and the resource does not target a cluster of its own
+func isDeferred(cluster interface{}, m interface{}) bool {
+	l := cluster.([]interface{})
+	if len(l) > 0 && l[0] != nil {
+		return false
+	}
+
+	return m.(*Config).Deferred
+}
@@ -0,0 +1,110 @@
+package kustomize
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/client-go/rest"
+)
+
+func testClusterConfig() *Config {
+	return &Config{
+		Namespace:  "default-namespace",
+		RestConfig: &rest.Config{QPS: 10, Burst: 20},
+		Clusters:   newClusterCache(),
+	}
+}
+
+func testCluster(host string, token string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"kubeconfig_raw":         "",
+			"context":                "",
+			"host":                   host,
+			"token":                  token,
+			"cluster_ca_certificate": "",
+		},
+	}
+}
+
+func TestGetResourceConfigWithoutCluster(t *testing.T) {
+	c := testClusterConfig()
+
+	m, err := getResourceConfig([]interface{}{}, c)
+	assert.Equal(t, nil, err)
+	assert.True(t, m.(*Config) == c)
+}
+
+func TestGetResourceConfigCachesClients(t *testing.T) {
+	c := testClusterConfig()
+
+	a, err := getResourceConfig(testCluster("https://a.example.com", "token"), c)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", a.(*Config).Namespace)
+	assert.NotEqual(t, nil, a.(*Config).Client)
+
+	again, err := getResourceConfig(testCluster("https://a.example.com", "token"), c)
+	assert.Equal(t, nil, err)
+	assert.True(t, a.(*Config).Mapper == again.(*Config).Mapper)
+
+	b, err := getResourceConfig(testCluster("https://b.example.com", "token"), c)
+	assert.Equal(t, nil, err)
+	assert.True(t, a.(*Config).Mapper != b.(*Config).Mapper)
+
+	// CRDs of one cluster don't validate custom resources of another
+	assert.True(t, a.(*Config).CRDSchemas == again.(*Config).CRDSchemas)
+	assert.True(t, a.(*Config).CRDSchemas != b.(*Config).CRDSchemas)
+
+	assert.Equal(t, 2, len(c.Clusters.clusters))
+}
+
+func TestGetResourceConfigKubeconfigRaw(t *testing.T) {
+	c := testClusterConfig()
+
+	cluster := testCluster("", "")
+	cluster[0].(map[string]interface{})["kubeconfig_raw"] = testKubeconfigB
+
+	_, err := getResourceConfig(cluster, c)
+	assert.Equal(t, nil, err)
+
+	_, err = getResourceConfig(testCluster("", ""), c)
+	assert.EqualError(t, err, "cluster: one of kubeconfig_raw or host must be set")
+}
+
+func TestGetResourceConfigProviderSettings(t *testing.T) {
+	c := testClusterConfig()
+	proxy, _ := url.Parse("http://proxy.example.com:3128")
+	c.RestConfig.Proxy = http.ProxyURL(proxy)
+	c.RestConfig.TLSClientConfig.ServerName = "provider.example.com"
+	c.RestConfig.Impersonate = rest.ImpersonationConfig{UserName: "system:serviceaccount:example:deployer"}
+
+	m, err := getResourceConfig(testCluster("https://a.example.com", "token"), c)
+	assert.Equal(t, nil, err)
+
+	rc := m.(*Config).RestConfig
+	assert.Equal(t, "https://a.example.com", rc.Host)
+	u, err := rc.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "a.example.com"}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, proxy, u)
+	assert.Equal(t, "system:serviceaccount:example:deployer", rc.Impersonate.UserName)
+	// the server name of the provider's host does not apply
+	assert.Equal(t, "", rc.TLSClientConfig.ServerName)
+}
+
+func TestClusterSchemaForceNew(t *testing.T) {
+	s := getClusterSchema().Elem.(*schema.Resource).Schema
+
+	// a different cluster requires re-creating the object
+	for _, k := range []string{"kubeconfig_raw", "context", "host"} {
+		assert.True(t, s[k].ForceNew, k)
+	}
+
+	// rotating credentials only update the state
+	for _, k := range []string{"token", "cluster_ca_certificate"} {
+		assert.False(t, s[k].ForceNew, k)
+	}
+}
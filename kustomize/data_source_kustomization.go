@@ -9,11 +9,13 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 )
 
 func getIDFromResources(rm resmap.ResMap) (s string, err error) {
@@ -55,6 +57,88 @@ func determinePrefix(kr *kManifestId) (p uint32) {
 	return p
 }
 
+const applyPriorityAnnotation = "kustomization.kubestack.com/apply-priority"
+
+// kindInstallOrder follows Helm's InstallOrder,
+// objects of a kind are applied before objects of kinds later in the list
+var kindInstallOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// kinds applied after all other kinds, including unknown ones
+var kindInstallOrderLast = []string{
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// determinePriority returns the position of the object in the install order
+// in steps of 10, unless overwritten using the apply-priority annotation
+func determinePriority(kr *kManifestId, annotations map[string]string) (p int, err error) {
+	if v, ok := annotations[applyPriorityAnnotation]; ok {
+		p, err = strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return p, fmt.Errorf("%q: invalid %q annotation: %q is not an integer", kr.string(), applyPriorityAnnotation, v)
+		}
+		return p, nil
+	}
+
+	if kr.kind == "PriorityClass" && kr.group == "scheduling.k8s.io" {
+		// applied right after namespaces, as in Helm 3.14+
+		return 15, nil
+	}
+
+	for i, k := range kindInstallOrder {
+		if kr.kind == k {
+			return (i + 1) * 10, nil
+		}
+	}
+
+	// unknown kinds, e.g. custom resources
+	p = (len(kindInstallOrder) + 1) * 10
+
+	for i, k := range kindInstallOrderLast {
+		if kr.kind == k {
+			return p + (i+1)*10, nil
+		}
+	}
+
+	return p, nil
+}
+
 func prefixHash(p uint32, h uint32) int {
 	s := fmt.Sprintf("%01d%010d", p, h)
 	s = s[0:9]
@@ -93,6 +177,36 @@ func runKustomizeBuild(fSys filesys.FileSystem, path string, kOpts *schema.Resou
 	return rm, nil
 }
 
+// checkAndSetGeneratedAttributes checks rm for apiVersions deprecated
+// or removed in the target_kube_version and against the policies. Only
+// without errors, it registers the CRDs of rm for schema validation
+// and sets the attributes.
+func checkAndSetGeneratedAttributes(d *schema.ResourceData, rm resmap.ResMap, m interface{}) diag.Diagnostics {
+	diags := checkAPIDeprecations(rm, getTargetKubeVersion(d.Get("target_kube_version").(string), m))
+
+	policies, err := getPolicies(d.Get("policy").([]interface{}))
+	if err != nil {
+		return append(diags, diag.Errorf("policy: %s", err)...)
+	}
+	diags = append(diags, checkPolicies(rm, policies)...)
+
+	if diags.HasError() {
+		return diags
+	}
+
+	// custom resources of the build are validated against the
+	// CRDs of the build, even if they are not installed yet
+	if c, ok := m.(*Config); ok && c.SchemaValidation {
+		c.CRDSchemas.addResMap(rm)
+	}
+
+	if err := setGeneratedAttributes(d, rm); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
 func setGeneratedAttributes(d *schema.ResourceData, rm resmap.ResMap) error {
 	ids, idsPrio, err := flattenKustomizationIDs(rm)
 	if err != nil {
@@ -101,11 +215,51 @@ func setGeneratedAttributes(d *schema.ResourceData, rm resmap.ResMap) error {
 	d.Set("ids", ids)
 	d.Set("ids_prio", idsPrio)
 
+	idsOrdered, err := flattenKustomizationIDsOrdered(rm)
+	if err != nil {
+		return fmt.Errorf("couldn't flatten ordered kustomization IDs: %s", err)
+	}
+	d.Set("ids_ordered", idsOrdered)
+
 	resources, err := flattenKustomizationResources(rm)
 	if err != nil {
 		return fmt.Errorf("couldn't flatten resources: %s", err)
 	}
-	d.Set("manifests", resources)
+	sfs, err := getSensitiveFields(d.Get("sensitive_fields").([]interface{}))
+	if err != nil {
+		return err
+	}
+
+	selectors := getSensitiveSelectors(d.Get("sensitive_selector").([]interface{}))
+
+	sensitiveResources, err := flattenKustomizationSensitiveResources(rm, resources, sfs, selectors)
+	if err != nil {
+		return fmt.Errorf("couldn't flatten sensitive resources: %s", err)
+	}
+
+	sensitiveIds := []string{}
+	for id := range sensitiveResources {
+		sensitiveIds = append(sensitiveIds, id)
+	}
+	d.Set("sensitive_ids", sensitiveIds)
+	d.Set("sensitive_manifests", sensitiveResources)
+
+	// sensitive resources are only returned in sensitive_manifests,
+	// unless they are explicitly requested in manifests as well
+	nonSensitiveResources := make(map[string]string)
+	for id, manifest := range resources {
+		_, sensitive := sensitiveResources[id]
+		if !sensitive || d.Get("include_sensitive_in_manifests").(bool) {
+			nonSensitiveResources[id] = manifest
+		}
+	}
+	d.Set("manifests", nonSensitiveResources)
+
+	dependencies, err := flattenKustomizationDependencies(resources)
+	if err != nil {
+		return fmt.Errorf("couldn't flatten dependencies: %s", err)
+	}
+	d.Set("dependencies", dependencies)
 
 	id, err := getIDFromResources(rm)
 	if err != nil {
@@ -116,6 +270,66 @@ func setGeneratedAttributes(d *schema.ResourceData, rm resmap.ResMap) error {
 	return nil
 }
 
+func getSensitiveSelectorSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"group": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"version": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"kind": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"namespace": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"label_selector": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"annotation_selector": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+func getSensitiveSelectors(in []interface{}) (selectors []types.Selector) {
+	for _, i := range in {
+		if i == nil {
+			continue
+		}
+		s := convertMapStringInterfaceToMapStringString(i.(map[string]interface{}))
+
+		selectors = append(selectors, types.Selector{
+			ResId: resid.ResId{
+				Gvk: resid.Gvk{
+					Group:   s["group"],
+					Version: s["version"],
+					Kind:    s["kind"],
+				},
+				Name:      s["name"],
+				Namespace: s["namespace"],
+			},
+			AnnotationSelector: s["annotation_selector"],
+			LabelSelector:      s["label_selector"],
+		})
+	}
+
+	return selectors
+}
+
 func getKustomizeOptions(d *schema.ResourceData) (opts *krusty.Options) {
 
 	opts = krusty.MakeDefaultOptions()
@@ -1,8 +1,9 @@
 package kustomize
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -10,7 +11,7 @@ import (
 
 func dataSourceKustomization() *schema.Resource {
 	return &schema.Resource{
-		Read: kustomizationBuild,
+		ReadContext: kustomizationBuild,
 
 		Schema: map[string]*schema.Schema{
 			"path": &schema.Schema{
@@ -64,16 +65,79 @@ func dataSourceKustomization() *schema.Resource {
 					Set:  idSetHash,
 				},
 			},
+			"ids_ordered": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"manifests": &schema.Schema{
 				Type:     schema.TypeMap,
 				Computed: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
+			"sensitive_fields": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSensitiveField,
+				},
+			},
+			"sensitive_manifests": &schema.Schema{
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"sensitive_ids": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Set:      idSetHash,
+			},
+			"sensitive_selector": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     getSensitiveSelectorSchema(),
+			},
+			"include_sensitive_in_manifests": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"target_kube_version": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateKubeVersion,
+			},
+			"policy": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     getPolicySchema(),
+			},
+			"dependencies": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"depends_on": {
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+							Set:      idSetHash,
+						},
+					},
+				},
+			},
 		},
 	}
 }
 
-func kustomizationBuild(d *schema.ResourceData, m interface{}) error {
+func kustomizationBuild(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	path := d.Get("path").(string)
 
 	fSys := filesys.MakeFsOnDisk()
@@ -85,8 +149,8 @@ func kustomizationBuild(d *schema.ResourceData, m interface{}) error {
 	rm, err := runKustomizeBuild(fSys, path, d)
 	mu.Unlock()
 	if err != nil {
-		return fmt.Errorf("kustomizationBuild: %s", err)
+		return diag.Errorf("kustomizationBuild: %s", err)
 	}
 
-	return setGeneratedAttributes(d, rm)
+	return checkAndSetGeneratedAttributes(d, rm, m)
 }
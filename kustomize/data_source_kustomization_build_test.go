@@ -21,7 +21,10 @@ func TestAccDataSourceKustomization_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("data.kustomization_build.test", "path", "test_kustomizations/basic/initial"),
 					resource.TestCheckResourceAttr("data.kustomization_build.test", "ids.#", "4"),
 					resource.TestCheckResourceAttr("data.kustomization_build.test", "ids_prio.#", "3"),
+					resource.TestCheckResourceAttr("data.kustomization_build.test", "ids_ordered.#", "4"),
+					resource.TestCheckResourceAttr("data.kustomization_build.test", "ids_ordered.0", "_/Namespace/_/test-basic"),
 					resource.TestCheckResourceAttr("data.kustomization_build.test", "manifests.%", "4"),
+					resource.TestCheckResourceAttr("data.kustomization_build.test", "dependencies.#", "4"),
 				),
 			},
 		},
@@ -0,0 +1,374 @@
+package kustomize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pmezard/go-difflib/difflib"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// change types, following the Terraform plan actions
+const (
+	changeTypeCreate  = "create"
+	changeTypeUpdate  = "update"
+	changeTypeNoop    = "noop"
+	changeTypeReplace = "replace"
+)
+
+const sensitiveDiffValue = "(sensitive value)"
+
+// fields set by the API server, that are not part of the diff
+var diffIgnoredFields = [][]string{
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+	{"status"},
+}
+
+func dataSourceKustomizationDiff() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: collectWarnings(kustomizationDiff),
+
+		Schema: map[string]*schema.Schema{
+			"cluster": getClusterSchema(),
+			"manifests": &schema.Schema{
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"sensitive_fields": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSensitiveField,
+				},
+			},
+			"has_changes": &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"diff": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"results": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"change_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"diff": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func kustomizationDiff(ctx context.Context, d *schema.ResourceData, m interface{}) (diags diag.Diagnostics) {
+	if isDeferred(d.Get("cluster"), m) {
+		return diag.Errorf("kustomizationDiff: cluster not known until apply, can not dry-run")
+	}
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	manifests := getManifestsFromResourceData(d.Get("manifests"))
+
+	sfs, err := getSensitiveFields(d.Get("sensitive_fields").([]interface{}))
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	ids := []string{}
+	for id := range manifests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	hasChanges := false
+	diffs := []string{}
+	results := []interface{}{}
+	for _, id := range ids {
+		km := m.(*Config).newKManifest(ctx)
+		if err := km.load([]byte(manifests[id])); err != nil {
+			diags = append(diags, diag.Errorf("%q: %s", id, err)...)
+			continue
+		}
+
+		changeType, diff, err := km.dryRunDiff(m.(*Config).GzipLastAppliedConfig, sfs)
+		if err != nil {
+			diags = append(diags, diag.FromErr(logError(err))...)
+			continue
+		}
+
+		if changeType != changeTypeNoop {
+			hasChanges = true
+			diffs = append(diffs, diff)
+		}
+
+		results = append(results, map[string]interface{}{
+			"id":          id,
+			"change_type": changeType,
+			"diff":        diff,
+		})
+	}
+
+	if diags.HasError() {
+		return diags
+	}
+
+	d.Set("has_changes", hasChanges)
+	d.Set("diff", strings.Join(diffs, ""))
+	d.Set("results", results)
+	d.SetId(getIDFromManifests(manifests))
+
+	return diags
+}
+
+// dryRunDiff returns how applying km would change the cluster, and the
+// diff between the live object and the result of a server-side dry-run,
+// with the values of sfs masked
+func (km *kManifest) dryRunDiff(gzipLastAppliedConfig bool, sfs []sensitiveField) (changeType string, diff string, err error) {
+	// the same as a plan, manifests are never applied under another version
+	if err := km.checkAPIVersionServed(); err != nil {
+		return changeType, diff, err
+	}
+	setLastAppliedConfig(km, gzipLastAppliedConfig)
+
+	if _, err := km.mappings(); err != nil {
+		// kinds not installed yet, e.g. of a CRD in the same
+		// build, can't be dry-run, diff the manifest instead
+		diff, err = km.unifiedDiff(nil, km.resource, sfs)
+		return changeTypeCreate, diff, err
+	}
+
+	dryRun := []string{k8smetav1.DryRunAll}
+
+	current, err := km.apiGet(k8smetav1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return changeType, diff, km.fmtErr(fmt.Errorf("get failed: %s", err))
+		}
+
+		created, err := km.apiCreate(k8smetav1.CreateOptions{DryRun: dryRun})
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return changeType, diff, km.fmtErr(fmt.Errorf("dry-run create failed: %s", err))
+			}
+
+			// the namespace does not exist yet
+			created = km.resource
+		}
+
+		diff, err = km.unifiedDiff(nil, created, sfs)
+		return changeTypeCreate, diff, err
+	}
+
+	// three-way patch, the same way kubectl apply diffs
+	// against the last applied configuration
+	original := getLastAppliedConfig(current, gzipLastAppliedConfig)
+	currentJSON, err := current.MarshalJSON()
+	if err != nil {
+		return changeType, diff, km.fmtErr(err)
+	}
+
+	pt, p, err := getPatch(km.gvk(), []byte(original), km.json, currentJSON)
+	if err != nil {
+		return changeType, diff, km.fmtErr(err)
+	}
+
+	patched, err := km.apiPatch(pt, p, k8smetav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		if requiresRecreate(err) {
+			// the manifest has no defaults, only diff the live
+			// fields of the previous or, without one, this manifest
+			fields := km.resource.Object
+			if original != "" {
+				fields = make(map[string]interface{})
+				if err := json.Unmarshal([]byte(original), &fields); err != nil {
+					return changeType, diff, km.fmtErr(fmt.Errorf("last applied configuration: %s", err))
+				}
+			}
+
+			before := &k8sunstructured.Unstructured{Object: pruneToFields(current.Object, fields).(map[string]interface{})}
+			diff, err = km.unifiedDiff(before, km.resource, sfs)
+			return changeTypeReplace, diff, err
+		}
+
+		return changeType, diff, km.fmtErr(fmt.Errorf("dry-run patch failed: %s", err))
+	}
+
+	if reflect.DeepEqual(normalizeForDiff(current).Object, normalizeForDiff(patched).Object) {
+		return changeTypeNoop, "", nil
+	}
+
+	diff, err = km.unifiedDiff(current, patched, sfs)
+	return changeTypeUpdate, diff, err
+}
+
+// unifiedDiff returns the unified diff of the YAML of before and after,
+// with the values of sfs masked, before is nil for objects that don't
+// exist yet
+func (km *kManifest) unifiedDiff(before *k8sunstructured.Unstructured, after *k8sunstructured.Unstructured, sfs []sensitiveField) (string, error) {
+	from := ""
+	a := normalizeForDiff(after)
+	if before != nil {
+		b := normalizeForDiff(before)
+		maskSensitiveFields(b, a, sfs)
+
+		y, err := yaml.Marshal(b.Object)
+		if err != nil {
+			return "", km.fmtErr(err)
+		}
+		from = string(y)
+	} else {
+		maskSensitiveFields(nil, a, sfs)
+	}
+
+	to, err := yaml.Marshal(a.Object)
+	if err != nil {
+		return "", km.fmtErr(err)
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from),
+		B:        splitLines(string(to)),
+		FromFile: km.id().string() + " (live)",
+		ToFile:   km.id().string() + " (dry-run)",
+		Context:  3,
+	})
+}
+
+// splitLines splits s into lines, keeping the line endings
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// normalizeForDiff returns a copy of u without the fields
+// set by the API server and the last applied configuration
+func normalizeForDiff(u *k8sunstructured.Unstructured) *k8sunstructured.Unstructured {
+	n := u.DeepCopy()
+
+	for _, f := range diffIgnoredFields {
+		k8sunstructured.RemoveNestedField(n.Object, f...)
+	}
+
+	annotations := n.GetAnnotations()
+	delete(annotations, lastAppliedConfigAnnotation)
+	delete(annotations, gzipLastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		k8sunstructured.RemoveNestedField(n.Object, "metadata", "annotations")
+	} else {
+		n.SetAnnotations(annotations)
+	}
+
+	normalizeSecretData(n)
+
+	return n
+}
+
+// pruneToFields returns v without the map keys not set in fields,
+// lists of a different length than in fields are returned as they are
+func pruneToFields(v interface{}, fields interface{}) interface{} {
+	switch f := fields.(type) {
+	case map[string]interface{}:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+
+		pruned := make(map[string]interface{})
+		for k, fv := range f {
+			if mv, ok := m[k]; ok {
+				pruned[k] = pruneToFields(mv, fv)
+			}
+		}
+		return pruned
+	case []interface{}:
+		l, ok := v.([]interface{})
+		if !ok || len(l) != len(f) {
+			return v
+		}
+
+		pruned := make([]interface{}, len(l))
+		for i := range l {
+			pruned[i] = pruneToFields(l[i], f[i])
+		}
+		return pruned
+	}
+
+	return v
+}
+
+// maskSensitiveFields replaces the values of sfs, changed values are
+// marked as changed in after, so the diff shows the keys of maps
+func maskSensitiveFields(before *k8sunstructured.Unstructured, after *k8sunstructured.Unstructured, sfs []sensitiveField) {
+	for _, sf := range sfs {
+		if !sf.matches(after) {
+			continue
+		}
+
+		var bv interface{}
+		var bok bool
+		if before != nil {
+			bv, bok, _ = k8sunstructured.NestedFieldNoCopy(before.Object, sf.path...)
+			if bok {
+				k8sunstructured.SetNestedField(before.Object, maskSensitiveValue(bv, nil, false), sf.path...)
+			}
+		}
+
+		if av, ok, _ := k8sunstructured.NestedFieldNoCopy(after.Object, sf.path...); ok {
+			k8sunstructured.SetNestedField(after.Object, maskSensitiveValue(av, bv, bok), sf.path...)
+		}
+	}
+}
+
+// maskSensitiveValue masks v, or each value of v if it is a map, and
+// marks them as changed, if prior is set and differs
+func maskSensitiveValue(v interface{}, prior interface{}, hasPrior bool) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if hasPrior && !reflect.DeepEqual(v, prior) {
+			return sensitiveDiffValue + " (changed)"
+		}
+		return sensitiveDiffValue
+	}
+
+	pm, _ := prior.(map[string]interface{})
+	masked := make(map[string]interface{})
+	for k, mv := range m {
+		pv, ok := pm[k]
+		masked[k] = maskSensitiveValue(mv, pv, ok)
+	}
+	return masked
+}
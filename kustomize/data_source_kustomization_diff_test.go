@@ -0,0 +1,212 @@
+package kustomize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testExampleGVR = k8sschema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "examples"}
+
+const testDiffExample = `{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": 1, "image": "app:v1"}}`
+
+func testDiffClient(t *testing.T, live ...string) (*restmapper.DeferredDiscoveryRESTMapper, *fakedynamic.FakeDynamicClient) {
+	fake := newCountingDiscovery(
+		&k8smetav1.APIResourceList{
+			GroupVersion: "v1",
+			APIResources: []k8smetav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"get"}},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: []string{"get"}},
+			},
+		},
+		&k8smetav1.APIResourceList{
+			GroupVersion: "example.com/v1",
+			APIResources: []k8smetav1.APIResource{
+				{Name: "examples", Kind: "Example", Namespaced: true, Verbs: []string{"get"}},
+			},
+		},
+	)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(newDiscoveryCache(fake, "", 0))
+
+	// live objects, applied by the provider before
+	objs := []k8sruntime.Object{}
+	for _, l := range live {
+		km := &kManifest{}
+		err := km.load([]byte(l))
+		assert.Equal(t, nil, err)
+		setLastAppliedConfig(km, false)
+		km.resource.SetResourceVersion("1")
+		km.resource.SetUID("00000000-0000-0000-0000-000000000000")
+		objs = append(objs, km.resource)
+	}
+
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(
+		k8sruntime.NewScheme(),
+		map[k8sschema.GroupVersionResource]string{
+			testConfigMapGVR: "ConfigMapList",
+			testExampleGVR:   "ExampleList",
+		},
+		objs...,
+	)
+
+	return mapper, client
+}
+
+func testDryRunDiff(t *testing.T, mapper *restmapper.DeferredDiscoveryRESTMapper, client *fakedynamic.FakeDynamicClient, manifest string) (string, string) {
+	km := newKManifest(mapper, client)
+	err := km.load([]byte(manifest))
+	assert.Equal(t, nil, err)
+
+	sfs, err := getSensitiveFields(nil)
+	assert.Equal(t, nil, err)
+
+	changeType, diff, err := km.dryRunDiff(false, sfs)
+	assert.Equal(t, nil, err)
+
+	return changeType, diff
+}
+
+func TestDryRunDiffCreate(t *testing.T) {
+	mapper, client := testDiffClient(t)
+
+	changeType, diff := testDryRunDiff(t, mapper, client, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}, "data": {"key": "value"}}`)
+	assert.Equal(t, changeTypeCreate, changeType)
+	assert.Equal(t, `--- _/ConfigMap/test/test (live)
++++ _/ConfigMap/test/test (dry-run)
+@@ -0,0 +1,7 @@
++apiVersion: v1
++data:
++  key: value
++kind: ConfigMap
++metadata:
++  name: test
++  namespace: test
+`, diff)
+
+	assert.Equal(t, 1, countActions(client, "create"))
+
+	// kinds not installed yet are diffed without a dry-run
+	changeType, diff = testDryRunDiff(t, mapper, client, `{"apiVersion": "unknown.example.com/v1", "kind": "Unknown", "metadata": {"name": "test"}}`)
+	assert.Equal(t, changeTypeCreate, changeType)
+	assert.Contains(t, diff, "+kind: Unknown\n")
+}
+
+func TestDryRunDiffUpdate(t *testing.T) {
+	mapper, client := testDiffClient(t, testDiffExample)
+
+	changeType, diff := testDryRunDiff(t, mapper, client, testDiffExample)
+	assert.Equal(t, changeTypeNoop, changeType)
+	assert.Equal(t, "", diff)
+
+	changeType, diff = testDryRunDiff(t, mapper, client, `{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": 2, "image": "app:v1"}}`)
+	assert.Equal(t, changeTypeUpdate, changeType)
+	assert.Equal(t, `--- example.com/Example/test/test (live)
++++ example.com/Example/test/test (dry-run)
+@@ -5,4 +5,4 @@
+   namespace: test
+ spec:
+   image: app:v1
+-  replicas: 1
++  replicas: 2
+`, diff)
+}
+
+func TestDryRunDiffReplace(t *testing.T) {
+	mapper, client := testDiffClient(t, testDiffExample)
+	client.PrependReactor("patch", "examples", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, nil, k8serrors.NewInvalid(
+			k8sschema.GroupKind{Group: "example.com", Kind: "Example"},
+			"test",
+			field.ErrorList{field.Invalid(field.NewPath("spec", "image"), "app:v2", "field is immutable")},
+		)
+	})
+
+	// fields defaulted by the API server are not part of the manifest
+	live, err := client.Resource(testExampleGVR).Namespace("test").Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.Equal(t, nil, err)
+	live.SetLabels(map[string]string{"defaulted": "true"})
+	k8sunstructured.SetNestedField(live.Object, "IfNotPresent", "spec", "pullPolicy")
+	err = client.Tracker().Update(testExampleGVR, live, "test")
+	assert.Equal(t, nil, err)
+
+	changeType, diff := testDryRunDiff(t, mapper, client, `{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": 1, "image": "app:v2"}}`)
+	assert.Equal(t, changeTypeReplace, changeType)
+	assert.Equal(t, `--- example.com/Example/test/test (live)
++++ example.com/Example/test/test (dry-run)
+@@ -4,5 +4,5 @@
+   name: test
+   namespace: test
+ spec:
+-  image: app:v1
++  image: app:v2
+   replicas: 1
+`, diff)
+}
+
+func TestDryRunDiffSecret(t *testing.T) {
+	km := &kManifest{}
+	err := km.load([]byte(`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "test", "namespace": "test"}, "data": {"a": "YQ==", "b": "Yg=="}}`))
+	assert.Equal(t, nil, err)
+	live := km.resource.DeepCopy()
+
+	err = km.load([]byte(`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "test", "namespace": "test"}, "data": {"a": "YQ=="}, "stringData": {"b": "changed", "c": "new"}}`))
+	assert.Equal(t, nil, err)
+
+	sfs, err := getSensitiveFields(nil)
+	assert.Equal(t, nil, err)
+
+	diff, err := km.unifiedDiff(live, km.resource, sfs)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `--- _/Secret/test/test (live)
++++ _/Secret/test/test (dry-run)
+@@ -1,7 +1,8 @@
+ apiVersion: v1
+ data:
+   a: (sensitive value)
+-  b: (sensitive value)
++  b: (sensitive value) (changed)
++  c: (sensitive value)
+ kind: Secret
+ metadata:
+   name: test
+`, diff)
+}
+
+func TestDryRunDiffSensitiveFields(t *testing.T) {
+	km := &kManifest{}
+	err := km.load([]byte(`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": 1, "password": "old", "credentials": {"a": "a", "b": "b"}}}`))
+	assert.Equal(t, nil, err)
+	live := km.resource.DeepCopy()
+
+	err = km.load([]byte(`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": 1, "password": "new", "credentials": {"a": "a", "b": "changed"}}}`))
+	assert.Equal(t, nil, err)
+
+	sfs, err := getSensitiveFields([]interface{}{"example.com/Example:spec.password", "example.com/Example:spec.credentials"})
+	assert.Equal(t, nil, err)
+
+	diff, err := km.unifiedDiff(live, km.resource, sfs)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `--- example.com/Example/test/test (live)
++++ example.com/Example/test/test (dry-run)
+@@ -6,6 +6,6 @@
+ spec:
+   credentials:
+     a: (sensitive value)
+-    b: (sensitive value)
+-  password: (sensitive value)
++    b: (sensitive value) (changed)
++  password: (sensitive value) (changed)
+   replicas: 1
+`, diff)
+}
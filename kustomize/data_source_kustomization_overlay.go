@@ -2,11 +2,13 @@ package kustomize
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
@@ -130,7 +132,7 @@ func getPatchOptions(o map[string]interface{}) map[string]bool {
 
 func dataSourceKustomizationOverlay() *schema.Resource {
 	return &schema.Resource{
-		Read: kustomizationOverlay,
+		ReadContext: kustomizationOverlay,
 
 		// support almost all attributes available in a Kustomization
 		//
@@ -641,11 +643,74 @@ func dataSourceKustomizationOverlay() *schema.Resource {
 					Set:  idSetHash,
 				},
 			},
+			"ids_ordered": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"manifests": &schema.Schema{
 				Type:     schema.TypeMap,
 				Computed: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
+			"sensitive_fields": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSensitiveField,
+				},
+			},
+			"sensitive_manifests": &schema.Schema{
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"sensitive_ids": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Set:      idSetHash,
+			},
+			"sensitive_selector": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     getSensitiveSelectorSchema(),
+			},
+			"include_sensitive_in_manifests": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"target_kube_version": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateKubeVersion,
+			},
+			"policy": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     getPolicySchema(),
+			},
+			"dependencies": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"depends_on": {
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+							Set:      idSetHash,
+						},
+					},
+				},
+			},
 			"kustomize_options": &schema.Schema{
 				Type:     schema.TypeList,
 				Optional: true,
@@ -1120,7 +1185,7 @@ func refuseExistingKustomization(fSys filesys.FileSystem) error {
 	return nil
 }
 
-func kustomizationOverlay(d *schema.ResourceData, m interface{}) error {
+func kustomizationOverlay(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	k := getKustomization(d)
 
 	var b bytes.Buffer
@@ -1132,13 +1197,13 @@ func kustomizationOverlay(d *schema.ResourceData, m interface{}) error {
 	fSys, tmp, err := makeOverlayFS(filesys.MakeFsOnDisk())
 	defer os.RemoveAll(tmp)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	// error if the current working directory is already a Kustomization
 	err = refuseExistingKustomization(fSys)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	fSys.WriteFile(KFILENAME, data)
@@ -1151,8 +1216,8 @@ func kustomizationOverlay(d *schema.ResourceData, m interface{}) error {
 	rm, err := runKustomizeBuild(fSys, ".", d)
 	mu.Unlock()
 	if err != nil {
-		return fmt.Errorf("buildKustomizeOverlay: %s", err)
+		return diag.Errorf("buildKustomizeOverlay: %s", err)
 	}
 
-	return setGeneratedAttributes(d, rm)
+	return checkAndSetGeneratedAttributes(d, rm, m)
 }
@@ -544,7 +544,8 @@ output "check_configmap" {
 }
 
 output "check_secret" {
-	value = data.kustomization_overlay.test.manifests["_/Secret/_/test-secret"]
+	value     = data.kustomization_overlay.test.sensitive_manifests["_/Secret/_/test-secret"]
+	sensitive = true
 }
 `
 }
@@ -964,15 +965,95 @@ data "kustomization_overlay" "test" {
 }
 
 output "check_cm1" {
-	value = data.kustomization_overlay.test.manifests["_/Secret/_/test-secret1"]
+	value     = data.kustomization_overlay.test.sensitive_manifests["_/Secret/_/test-secret1"]
+	sensitive = true
 }
 
 output "check_cm2" {
-	value = data.kustomization_overlay.test.manifests["_/Secret/_/test-secret2-h55cfd6gfg"]
+	value     = data.kustomization_overlay.test.sensitive_manifests["_/Secret/_/test-secret2-h55cfd6gfg"]
+	sensitive = true
 }
 `
 }
 
+// Test sensitive_manifests routing
+func TestDataSourceKustomizationOverlay_sensitiveManifests(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		IsUnitTest: true,
+		Providers:  testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testKustomizationSensitiveManifestsConfig(false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "ids.#", "3"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "manifests.%", "1"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "sensitive_manifests.%", "2"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "sensitive_ids.#", "2"),
+					resource.TestCheckOutput("check_cm", "{\"apiVersion\":\"v1\",\"data\":{\"KEY1\":\"VALUE1\"},\"kind\":\"ConfigMap\",\"metadata\":{\"labels\":{\"sensitive\":\"true\"},\"name\":\"test-cm-sensitive\"}}"),
+				),
+			},
+			{
+				Config: testKustomizationSensitiveManifestsConfig(true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "ids.#", "3"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "manifests.%", "3"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "sensitive_manifests.%", "2"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "sensitive_ids.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+func testKustomizationSensitiveManifestsConfig(include bool) string {
+	return fmt.Sprintf(`
+data "kustomization_overlay" "test" {
+	generator_options {
+		disable_name_suffix_hash = true
+	}
+
+	config_map_generator {
+		name = "test-cm"
+		literals = [
+			"KEY1=VALUE1",
+		]
+	}
+
+	config_map_generator {
+		name = "test-cm-sensitive"
+		literals = [
+			"KEY1=VALUE1",
+		]
+		options {
+			labels = {
+				sensitive = "true"
+			}
+		}
+	}
+
+	secret_generator {
+		name = "test-secret"
+		literals = [
+			"KEY1=VALUE1",
+		]
+	}
+
+	sensitive_selector {
+		kind           = "ConfigMap"
+		label_selector = "sensitive=true"
+	}
+
+	include_sensitive_in_manifests = %t
+}
+
+output "check_cm" {
+	value     = data.kustomization_overlay.test.sensitive_manifests["_/ConfigMap/_/test-cm-sensitive"]
+	sensitive = true
+}
+`, include)
+}
+
 // Test vars attr
 func TestDataSourceKustomizationOverlay_vars(t *testing.T) {
 
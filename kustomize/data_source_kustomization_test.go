@@ -30,6 +30,47 @@ func TestDeterminePrefix(t *testing.T) {
 	}
 }
 
+func TestDeterminePriority(t *testing.T) {
+	ns, err := determinePriority(mustParseProviderId("_/Namespace/_/test"), nil)
+	assert.Equal(t, nil, err)
+
+	sa, err := determinePriority(mustParseProviderId("_/ServiceAccount/test-ns/test"), nil)
+	assert.Equal(t, nil, err)
+
+	crd, err := determinePriority(mustParseProviderId("apiextensions.k8s.io/CustomResourceDefinition/_/test"), nil)
+	assert.Equal(t, nil, err)
+
+	rb, err := determinePriority(mustParseProviderId("rbac.authorization.k8s.io/RoleBinding/test-ns/test"), nil)
+	assert.Equal(t, nil, err)
+
+	dep, err := determinePriority(mustParseProviderId("apps/Deployment/test-ns/test"), nil)
+	assert.Equal(t, nil, err)
+
+	cr, err := determinePriority(mustParseProviderId("test.example.com/Clusteredobject/_/test"), nil)
+	assert.Equal(t, nil, err)
+
+	wh, err := determinePriority(mustParseProviderId("admissionregistration.k8s.io/ValidatingWebhookConfiguration/_/test"), nil)
+	assert.Equal(t, nil, err)
+
+	assert.Less(t, ns, sa)
+	assert.Less(t, sa, crd)
+	assert.Less(t, crd, rb)
+	assert.Less(t, rb, dep)
+	assert.Less(t, dep, cr)
+	assert.Less(t, cr, wh)
+}
+
+func TestDeterminePriorityAnnotation(t *testing.T) {
+	kr := mustParseProviderId("apps/Deployment/test-ns/test")
+
+	p, err := determinePriority(kr, map[string]string{applyPriorityAnnotation: "5"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5, p)
+
+	_, err = determinePriority(kr, map[string]string{applyPriorityAnnotation: "first"})
+	assert.EqualError(t, err, "\"apps/Deployment/test-ns/test\": invalid \"kustomization.kubestack.com/apply-priority\" annotation: \"first\" is not an integer")
+}
+
 func TestPrefixHash(t *testing.T) {
 	ti := uint32(math.MaxInt32 / 1000)
 	i := prefixHash(uint32(1), ti)
@@ -0,0 +1,244 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// validation result statuses, following kubeconform
+const (
+	validateStatusValid   = "valid"
+	validateStatusInvalid = "invalid"
+	validateStatusError   = "error"
+	validateStatusSkipped = "skipped"
+)
+
+func dataSourceKustomizationValidate() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: kustomizationValidate,
+
+		Schema: map[string]*schema.Schema{
+			"manifests": &schema.Schema{
+				Type:     schema.TypeMap,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"kube_version": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateKubeVersion,
+			},
+			"ignore_missing_schemas": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"fail_on_error": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"schema_version": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"valid": &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"results": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"errors": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func kustomizationValidate(ctx context.Context, d *schema.ResourceData, m interface{}) (diags diag.Diagnostics) {
+	manifests := getManifestsFromResourceData(d.Get("manifests"))
+	kubeVersion := getTargetKubeVersion(d.Get("kube_version").(string), m)
+
+	schemaVersion, exact, err := getBundledSchemaVersion(kubeVersion)
+	if err != nil {
+		return diag.Errorf("kustomizationValidate: %s", err)
+	}
+	if !exact {
+		summary := fmt.Sprintf("No bundled schemas for Kubernetes %q", kubeVersion)
+		if kubeVersion == "" {
+			summary = "No kube_version or target_kube_version set"
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  summary,
+			Detail:   fmt.Sprintf("Validating against the schemas of Kubernetes %s. Fields unknown to this version are skipped instead of invalid, they may exist in newer versions.", schemaVersion),
+		})
+	}
+
+	doc, err := getBundledSchemas(schemaVersion)
+	if err != nil {
+		return append(diags, diag.Errorf("kustomizationValidate: %s", err)...)
+	}
+
+	ids := []string{}
+	for id := range manifests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// custom resources are validated against the CRDs of the manifests
+	crds := newCRDSchemas()
+	kms := make(map[string]*kManifest)
+	loadErrs := make(map[string]error)
+	for _, id := range ids {
+		km := &kManifest{}
+		if err := km.load([]byte(manifests[id])); err != nil {
+			loadErrs[id] = err
+			continue
+		}
+		kms[id] = km
+
+		if isCRD(km.resource) {
+			if err := crds.add(km.resource); err != nil {
+				loadErrs[id] = err
+			}
+		}
+	}
+
+	ignoreMissing := d.Get("ignore_missing_schemas").(bool)
+
+	// removed apiVersions are checked against the requested version,
+	// even if the schemas are of an older bundled version
+	removedVersion := schemaVersion
+	if kubeVersion != "" {
+		removedVersion = kubeVersion
+	}
+
+	valid := true
+	results := []interface{}{}
+	for _, id := range ids {
+		var status string
+		var errs []string
+		if err, ok := loadErrs[id]; ok {
+			status, errs = validateStatusError, []string{err.Error()}
+		} else {
+			status, errs = validateBundledSchema(kms[id], schemaVersion, removedVersion, doc, crds, ignoreMissing, exact)
+		}
+
+		if status == validateStatusSkipped && len(errs) > 0 {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("%q: fields unknown to Kubernetes %s", id, schemaVersion),
+				Detail:   strings.Join(errs, "\n"),
+			})
+		}
+
+		if status == validateStatusInvalid || status == validateStatusError {
+			valid = false
+
+			if d.Get("fail_on_error").(bool) {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("%q: %s", id, status),
+					Detail:   strings.Join(errs, "\n"),
+				})
+			}
+		}
+
+		results = append(results, map[string]interface{}{
+			"id":     id,
+			"status": status,
+			"errors": errs,
+		})
+	}
+
+	d.Set("schema_version", schemaVersion)
+	d.Set("valid", valid)
+	d.Set("results", results)
+	d.SetId(getIDFromManifests(manifests))
+
+	return diags
+}
+
+// validateBundledSchema validates km against the schema of its kind
+// from crds or the bundled schemas doc of schemaVersion, and against
+// the apiVersions removed in removedVersion. If schemaVersion is not
+// exact, objects with only unknown fields of built-in kinds are skipped.
+func validateBundledSchema(km *kManifest, schemaVersion string, removedVersion string, doc *openAPIDoc, crds *crdSchemas, ignoreMissing bool, exact bool) (status string, errs []string) {
+	gvk := km.gvk()
+
+	// removed versions may still have schemas, e.g. for internal use
+	if ad := findAPIDeprecation(gvk.Group, gvk.Version, gvk.Kind); ad != nil {
+		rv := version.MustParseGeneric(removedVersion)
+		if rv.AtLeast(version.MustParseGeneric(ad.removedIn)) {
+			return validateStatusInvalid, []string{
+				fmt.Sprintf("apiVersion %q of kind %q is removed in Kubernetes v%s, %s", ad.apiVersion(), ad.kind, ad.removedIn, ad.migrateTo(rv)),
+			}
+		}
+	}
+
+	s := crds.get(gvk)
+	resolve := func(string) *spec.Schema { return nil }
+	bundled := s == nil
+	if bundled {
+		s = doc.kinds[gvk]
+		resolve = doc.resolve
+	}
+
+	if s == nil {
+		if ignoreMissing {
+			return validateStatusSkipped, []string{}
+		}
+		return validateStatusError, []string{
+			fmt.Sprintf("no schema for apiVersion %q of kind %q in Kubernetes %s or the CRDs of the manifests", km.resource.GetAPIVersion(), gvk.Kind, schemaVersion),
+		}
+	}
+
+	errs = validateAgainstSchema(km.resource.Object, s, resolve)
+	if len(errs) == 0 {
+		return validateStatusValid, []string{}
+	}
+
+	// the CRDs of the manifests are exact, the bundled
+	// schemas may lack fields added in the requested version
+	if bundled && !exact {
+		unknown, other := []string{}, []string{}
+		for _, e := range errs {
+			if isUnknownFieldError(e) {
+				unknown = append(unknown, e)
+			} else {
+				other = append(other, e)
+			}
+		}
+
+		if len(other) == 0 {
+			return validateStatusSkipped, unknown
+		}
+		errs = other
+	}
+
+	return validateStatusInvalid, errs
+}
@@ -0,0 +1,173 @@
+package kustomize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func testValidateResults(d *schema.ResourceData) map[string]map[string]interface{} {
+	results := make(map[string]map[string]interface{})
+	for _, r := range d.Get("results").([]interface{}) {
+		m := r.(map[string]interface{})
+		results[m["id"].(string)] = m
+	}
+	return results
+}
+
+func TestDataSourceKustomizationValidate(t *testing.T) {
+	n, err := yaml.Parse(testSchemaValidationCRD)
+	assert.Equal(t, nil, err)
+	crd, err := n.MarshalJSON()
+	assert.Equal(t, nil, err)
+
+	d := schema.TestResourceDataRaw(t, dataSourceKustomizationValidate().Schema, map[string]interface{}{
+		"kube_version": "1.27",
+		"manifests": map[string]interface{}{
+			"apiextensions.k8s.io/CustomResourceDefinition/_/examples.example.com": string(crd),
+			"example.com/Example/test/valid":                                       `{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "valid", "namespace": "test"}, "spec": {"replicas": 1}}`,
+			"example.com/Example/test/invalid":                                     `{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "invalid", "namespace": "test"}, "spec": {"replicas": "1"}}`,
+			"apps/Deployment/test/test":                                            `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "test", "namespace": "test"}, "spec": {"replicas": "1", "template": {"spec": {"containers": [{"name": "test", "resources": {"limits": {"memory": 128}}, "ports": [{"containerPort": 80, "name": "http"}], "livenessProbe": {"httpGet": {"port": "http"}}}]}}}}`,
+			"_/Service/test/test":                                                  `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test", "namespace": "test"}, "spec": {"ports": [{"port": 80, "targetPort": "http"}]}}`,
+			"policy/PodSecurityPolicy/_/test":                                      `{"apiVersion": "policy/v1beta1", "kind": "PodSecurityPolicy", "metadata": {"name": "test"}}`,
+			"unknown.example.com/Unknown/_/test":                                   `{"apiVersion": "unknown.example.com/v1", "kind": "Unknown", "metadata": {"name": "test"}}`,
+		},
+	})
+
+	diags := kustomizationValidate(context.Background(), d, &Config{})
+	assert.False(t, diags.HasError())
+	assert.Equal(t, "v1.27", d.Get("schema_version"))
+	assert.False(t, d.Get("valid").(bool))
+
+	results := testValidateResults(d)
+	assert.Equal(t, 7, len(results))
+
+	expStatus := map[string]string{
+		"apiextensions.k8s.io/CustomResourceDefinition/_/examples.example.com": "valid",
+		"example.com/Example/test/valid":                                       "valid",
+		"example.com/Example/test/invalid":                                     "invalid",
+		"apps/Deployment/test/test":                                            "invalid",
+		"_/Service/test/test":                                                  "valid",
+		"policy/PodSecurityPolicy/_/test":                                      "invalid",
+		"unknown.example.com/Unknown/_/test":                                   "error",
+	}
+	for id, status := range expStatus {
+		assert.Equal(t, status, results[id]["status"], id)
+	}
+
+	assert.Equal(t, []interface{}{"spec.replicas: expected integer, got string"}, results["example.com/Example/test/invalid"]["errors"])
+	assert.Equal(t, []interface{}{"spec.replicas: expected integer, got string"}, results["apps/Deployment/test/test"]["errors"])
+}
+
+func TestDataSourceKustomizationValidateOptions(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceKustomizationValidate().Schema, map[string]interface{}{
+		"ignore_missing_schemas": true,
+		"fail_on_error":          true,
+		"manifests": map[string]interface{}{
+			"unknown.example.com/Unknown/_/test": `{"apiVersion": "unknown.example.com/v1", "kind": "Unknown", "metadata": {"name": "test"}}`,
+			"_/ConfigMap/test/test":              `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}, "data": {"key": 1}}`,
+		},
+	})
+
+	// the provider's target_kube_version is the default
+	diags := kustomizationValidate(context.Background(), d, &Config{TargetKubeVersion: "1.28"})
+	assert.True(t, diags.HasError())
+	assert.Equal(t, "v1.28", d.Get("schema_version"))
+
+	// only the error for the config map
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, `"_/ConfigMap/test/test": invalid`, diags[0].Summary)
+	assert.Equal(t, "data.key: expected string, got number", diags[0].Detail)
+
+	results := testValidateResults(d)
+	assert.Equal(t, "skipped", results["unknown.example.com/Unknown/_/test"]["status"])
+}
+
+func TestDataSourceKustomizationValidateVersions(t *testing.T) {
+	// Container.restartPolicy is added in Kubernetes v1.28
+	manifests := map[string]interface{}{
+		"_/Pod/test/test": `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test", "namespace": "test"}, "spec": {"containers": [{"name": "test", "restartPolicy": "Always"}]}}`,
+	}
+
+	for v, status := range map[string]string{"1.27": "invalid", "1.28": "valid"} {
+		d := schema.TestResourceDataRaw(t, dataSourceKustomizationValidate().Schema, map[string]interface{}{
+			"kube_version": v,
+			"manifests":    manifests,
+		})
+
+		diags := kustomizationValidate(context.Background(), d, &Config{})
+		assert.Equal(t, 0, len(diags), v)
+		assert.Equal(t, "v"+v, d.Get("schema_version"), v)
+
+		results := testValidateResults(d)
+		assert.Equal(t, status, results["_/Pod/test/test"]["status"], v)
+	}
+}
+
+func TestDataSourceKustomizationValidateNewerVersion(t *testing.T) {
+	manifests := map[string]interface{}{
+		// Lifecycle.stopSignal is not in the bundled v1.30 schemas
+		"_/Pod/test/unknown": `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "unknown", "namespace": "test"}, "spec": {"containers": [{"name": "test", "lifecycle": {"stopSignal": "SIGUSR1"}}]}}`,
+		"_/Pod/test/invalid": `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "invalid", "namespace": "test"}, "spec": {"containers": [{"name": "test", "lifecycle": {"stopSignal": "SIGUSR1"}, "tty": "true"}]}}`,
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceKustomizationValidate().Schema, map[string]interface{}{
+		"kube_version": "1.33",
+		"manifests":    manifests,
+	})
+
+	diags := kustomizationValidate(context.Background(), d, &Config{})
+	assert.False(t, diags.HasError())
+	assert.False(t, d.Get("valid").(bool))
+	assert.Equal(t, "v1.30", d.Get("schema_version"))
+
+	// warning for the older bundled version and for the unknown field
+	assert.Equal(t, 2, len(diags))
+	assert.Equal(t, `"_/Pod/test/unknown": fields unknown to Kubernetes v1.30`, diags[1].Summary)
+
+	results := testValidateResults(d)
+	assert.Equal(t, "skipped", results["_/Pod/test/unknown"]["status"])
+	assert.Equal(t, []interface{}{`spec.containers[0].lifecycle.stopSignal: unknown field "stopSignal"`}, results["_/Pod/test/unknown"]["errors"])
+	assert.Equal(t, "invalid", results["_/Pod/test/invalid"]["status"])
+	assert.Equal(t, []interface{}{"spec.containers[0].tty: expected boolean, got string"}, results["_/Pod/test/invalid"]["errors"])
+
+	// unknown fields are invalid for an exact version
+	d = schema.TestResourceDataRaw(t, dataSourceKustomizationValidate().Schema, map[string]interface{}{
+		"kube_version": "1.30",
+		"manifests":    manifests,
+	})
+
+	diags = kustomizationValidate(context.Background(), d, &Config{})
+	assert.Equal(t, 0, len(diags))
+
+	results = testValidateResults(d)
+	assert.Equal(t, "invalid", results["_/Pod/test/unknown"]["status"])
+}
+
+func TestGetBundledSchemaVersion(t *testing.T) {
+	v, exact, err := getBundledSchemaVersion("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "v1.30", v)
+	assert.False(t, exact)
+
+	v, exact, err = getBundledSchemaVersion("v1.27.3")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "v1.27", v)
+	assert.True(t, exact)
+
+	v, exact, err = getBundledSchemaVersion("1.25")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "v1.25", v)
+	assert.True(t, exact)
+
+	v, exact, err = getBundledSchemaVersion("1.31")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "v1.30", v)
+	assert.False(t, exact)
+
+	_, _, err = getBundledSchemaVersion("1.24")
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,203 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// apiDeprecation is a deprecated or removed apiVersion of a kind
+type apiDeprecation struct {
+	group        string
+	version      string
+	kind         string
+	deprecatedIn string
+	removedIn    string
+	// apiVersion to migrate to, empty if there is no replacement
+	replacement string
+}
+
+// apiDeprecations follows the Kubernetes deprecated API migration guide
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var apiDeprecations = []apiDeprecation{
+	// v1.16
+	{"extensions", "v1beta1", "Deployment", "1.8", "1.16", "apps/v1"},
+	{"extensions", "v1beta1", "DaemonSet", "1.8", "1.16", "apps/v1"},
+	{"extensions", "v1beta1", "ReplicaSet", "1.8", "1.16", "apps/v1"},
+	{"extensions", "v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions", "v1beta1", "PodSecurityPolicy", "1.10", "1.16", "policy/v1beta1"},
+	{"apps", "v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps", "v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps", "v1beta2", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps", "v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps", "v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"apps", "v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+
+	// v1.22
+	{"extensions", "v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io", "v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io", "v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"admissionregistration.k8s.io", "v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io", "v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io", "v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io", "v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io", "v1beta1", "TokenReview", "1.19", "1.22", "authentication.k8s.io/v1"},
+	{"authorization.k8s.io", "v1beta1", "LocalSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io", "v1beta1", "SelfSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io", "v1beta1", "SubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"certificates.k8s.io", "v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io", "v1beta1", "Lease", "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io", "v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io", "v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "StorageClass", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io", "v1beta1", "VolumeAttachment", "1.19", "1.22", "storage.k8s.io/v1"},
+
+	// v1.25
+	{"batch", "v1beta1", "CronJob", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io", "v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io", "v1beta1", "Event", "1.19", "1.25", "events.k8s.io/v1"},
+	{"autoscaling", "v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2"},
+	{"policy", "v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1"},
+	{"policy", "v1beta1", "PodSecurityPolicy", "1.21", "1.25", ""},
+	{"node.k8s.io", "v1beta1", "RuntimeClass", "1.20", "1.25", "node.k8s.io/v1"},
+
+	// v1.26
+	{"autoscaling", "v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+
+	// v1.27
+	{"storage.k8s.io", "v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1"},
+
+	// v1.29
+	{"flowcontrol.apiserver.k8s.io", "v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+
+	// v1.32
+	{"flowcontrol.apiserver.k8s.io", "v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io", "v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+func findAPIDeprecation(group, version, kind string) *apiDeprecation {
+	for i := range apiDeprecations {
+		d := &apiDeprecations[i]
+		if d.group == group && d.version == version && d.kind == kind {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (ad *apiDeprecation) apiVersion() string {
+	if ad.group == "" {
+		return ad.version
+	}
+	return ad.group + "/" + ad.version
+}
+
+// migrateTo returns the migration advice for Kubernetes version v,
+// following replacements that are removed in v as well
+func (ad *apiDeprecation) migrateTo(v *version.Version) string {
+	to := ad
+	for to.replacement != "" {
+		gv, err := k8sschema.ParseGroupVersion(to.replacement)
+		if err != nil {
+			break
+		}
+
+		next := findAPIDeprecation(gv.Group, gv.Version, ad.kind)
+		if next == nil || !v.AtLeast(version.MustParseGeneric(next.removedIn)) {
+			return fmt.Sprintf("use %q instead", to.replacement)
+		}
+		to = next
+	}
+
+	if to != ad {
+		return fmt.Sprintf("kind %q is removed entirely as of Kubernetes v%s, there is no replacement", ad.kind, to.removedIn)
+	}
+	return "there is no replacement"
+}
+
+func validateKubeVersion(v interface{}, k string) (ws []string, es []error) {
+	s := v.(string)
+	if s == "" {
+		return ws, es
+	}
+
+	if _, err := version.ParseGeneric(s); err != nil {
+		es = append(es, fmt.Errorf("%s: invalid Kubernetes version %q: %s", k, s, err))
+	}
+
+	return ws, es
+}
+
+// getTargetKubeVersion returns the target_kube_version of the data
+// source, or the provider default if the data source does not set one
+func getTargetKubeVersion(v string, m interface{}) string {
+	if v != "" {
+		return v
+	}
+
+	if c, ok := m.(*Config); ok && c != nil {
+		return c.TargetKubeVersion
+	}
+
+	return ""
+}
+
+// checkAPIDeprecations returns an error for every object in rm using an
+// apiVersion removed in target, and a warning for every object using
+// an apiVersion deprecated in target. No cluster access is required.
+func checkAPIDeprecations(rm resmap.ResMap, target string) (diags diag.Diagnostics) {
+	if target == "" {
+		return nil
+	}
+
+	tv, err := version.ParseGeneric(target)
+	if err != nil {
+		return diag.Errorf("target_kube_version: invalid Kubernetes version %q: %s", target, err)
+	}
+
+	for _, r := range rm.Resources() {
+		gvk := r.CurId().Gvk
+		ad := findAPIDeprecation(gvk.Group, gvk.Version, gvk.Kind)
+		if ad == nil {
+			continue
+		}
+
+		kr := &kManifestId{
+			group:     gvk.Group,
+			kind:      gvk.Kind,
+			namespace: r.GetNamespace(),
+			name:      r.GetName(),
+		}
+
+		if tv.AtLeast(version.MustParseGeneric(ad.removedIn)) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("%q: apiVersion %q of kind %q is removed in Kubernetes v%s", kr.string(), ad.apiVersion(), ad.kind, ad.removedIn),
+				Detail:   fmt.Sprintf("Not available in target_kube_version %q, %s.", target, ad.migrateTo(tv)),
+			})
+			continue
+		}
+
+		if tv.AtLeast(version.MustParseGeneric(ad.deprecatedIn)) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("%q: apiVersion %q of kind %q is deprecated since Kubernetes v%s", kr.string(), ad.apiVersion(), ad.kind, ad.deprecatedIn),
+				Detail:   fmt.Sprintf("Will be removed in Kubernetes v%s, %s.", ad.removedIn, ad.migrateTo(version.MustParseGeneric(ad.removedIn))),
+			})
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,118 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCheckAPIDeprecations(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("kustomization.yaml", []byte(`
+resources:
+- resources.yaml
+`))
+	fSys.WriteFile("resources.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: test
+`))
+
+	opts := krusty.MakeDefaultOptions()
+	k := krusty.MakeKustomizer(opts)
+
+	rm, err := k.Run(fSys, ".")
+	assert.Equal(t, err, nil, nil)
+
+	// no target version, no checks
+	assert.Equal(t, 0, len(checkAPIDeprecations(rm, "")))
+
+	// everything still served
+	assert.Equal(t, 0, len(checkAPIDeprecations(rm, "1.18")))
+
+	// ingress removed, cronjob and psp deprecated
+	diags := checkAPIDeprecations(rm, "v1.22.3")
+	assert.True(t, diags.HasError())
+	assert.Equal(t, 3, len(diags))
+	for _, d := range diags {
+		switch d.Severity {
+		case diag.Error:
+			assert.Equal(t, `"networking.k8s.io/Ingress/test/test": apiVersion "networking.k8s.io/v1beta1" of kind "Ingress" is removed in Kubernetes v1.22`, d.Summary)
+			assert.Contains(t, d.Detail, `use "networking.k8s.io/v1" instead`)
+		case diag.Warning:
+			assert.Contains(t, []string{
+				`"batch/CronJob/test/test": apiVersion "batch/v1beta1" of kind "CronJob" is deprecated since Kubernetes v1.21`,
+				`"policy/PodSecurityPolicy/_/test": apiVersion "policy/v1beta1" of kind "PodSecurityPolicy" is deprecated since Kubernetes v1.21`,
+			}, d.Summary)
+		}
+	}
+
+	// all removed
+	diags = checkAPIDeprecations(rm, "1.25")
+	assert.Equal(t, 3, len(diags))
+	for _, d := range diags {
+		assert.Equal(t, diag.Error, d.Severity)
+	}
+
+	diags = checkAPIDeprecations(rm, "not-a-version")
+	assert.True(t, diags.HasError())
+}
+
+func TestAPIDeprecationMigrateTo(t *testing.T) {
+	ad := findAPIDeprecation("extensions", "v1beta1", "PodSecurityPolicy")
+	assert.NotNil(t, ad)
+
+	// the replacement is still served
+	assert.Equal(t, `use "policy/v1beta1" instead`, ad.migrateTo(version.MustParseGeneric("1.16")))
+
+	// the replacement is removed as well
+	assert.Equal(t, `kind "PodSecurityPolicy" is removed entirely as of Kubernetes v1.25, there is no replacement`, ad.migrateTo(version.MustParseGeneric("1.25")))
+
+	// replacements of replacements, e.g. flowcontrol
+	ad = findAPIDeprecation("flowcontrol.apiserver.k8s.io", "v1beta2", "FlowSchema")
+	assert.Equal(t, `use "flowcontrol.apiserver.k8s.io/v1" instead`, ad.migrateTo(version.MustParseGeneric("1.29")))
+
+	ad = findAPIDeprecation("policy", "v1beta1", "PodSecurityPolicy")
+	assert.Equal(t, "there is no replacement", ad.migrateTo(version.MustParseGeneric("1.25")))
+}
+
+func TestGetTargetKubeVersion(t *testing.T) {
+	m := &Config{TargetKubeVersion: "1.25"}
+
+	assert.Equal(t, "1.27", getTargetKubeVersion("1.27", m))
+	assert.Equal(t, "1.25", getTargetKubeVersion("", m))
+	assert.Equal(t, "", getTargetKubeVersion("", &Config{}))
+}
+
+func TestValidateKubeVersion(t *testing.T) {
+	for _, v := range []string{"", "1.25", "v1.25", "1.25.3", "v1.29.0-eks"} {
+		_, es := validateKubeVersion(v, "target_kube_version")
+		assert.Equal(t, 0, len(es), v)
+	}
+
+	_, es := validateKubeVersion("latest", "target_kube_version")
+	assert.Equal(t, 1, len(es))
+}
@@ -0,0 +1,263 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// characters not allowed in the per-host cache directory name
+var discoveryCacheDirCharacters = regexp.MustCompile(`[^(\w/.)]`)
+
+// discoveryCache caches API discovery in memory and optionally on disk.
+// Unlike the memory and disk clients of client-go, it can invalidate
+// a single group version, instead of having to re-discover all APIs
+// every time a kind, e.g. of a CRD created during apply, is missing.
+type discoveryCache struct {
+	discovery.DiscoveryInterface
+
+	// disk cache directory, empty to only cache in memory
+	dir string
+	ttl time.Duration
+
+	mu        sync.Mutex
+	groups    *k8smetav1.APIGroupList
+	resources map[string]*k8smetav1.APIResourceList
+	// group versions to invalidate on the next Invalidate call
+	stale map[string]bool
+	// disk cache files older than this are ignored
+	invalidatedAt time.Time
+	// false, if data from disk has been used since the last Invalidate
+	fresh bool
+}
+
+var _ discovery.CachedDiscoveryInterface = &discoveryCache{}
+
+func newDiscoveryCache(delegate discovery.DiscoveryInterface, dir string, ttl time.Duration) *discoveryCache {
+	return &discoveryCache{
+		DiscoveryInterface: delegate,
+		dir:                dir,
+		ttl:                ttl,
+		resources:          make(map[string]*k8smetav1.APIResourceList),
+		stale:              make(map[string]bool),
+		fresh:              true,
+	}
+}
+
+// getDiscoveryCacheDir returns the cache directory for host below
+// parent, or an empty string, if parent or host are not set
+func getDiscoveryCacheDir(parent string, host string) string {
+	if parent == "" || host == "" {
+		return ""
+	}
+
+	schemeless := strings.Replace(strings.Replace(host, "https://", "", 1), "http://", "", 1)
+	safe := discoveryCacheDirCharacters.ReplaceAllString(schemeless, "_")
+
+	return filepath.Join(parent, safe)
+}
+
+func (dc *discoveryCache) ServerGroups() (*k8smetav1.APIGroupList, error) {
+	dc.mu.Lock()
+	groups := dc.groups
+	dc.mu.Unlock()
+
+	if groups != nil {
+		return groups, nil
+	}
+
+	// requests are made without holding the lock,
+	// so group versions can be discovered in parallel
+	filename := dc.filename("servergroups.json")
+	groups = &k8smetav1.APIGroupList{}
+	if !dc.readFile(filename, groups) {
+		var err error
+		groups, err = dc.DiscoveryInterface.ServerGroups()
+		if err != nil {
+			return groups, err
+		}
+		dc.writeFile(filename, groups)
+	}
+
+	dc.mu.Lock()
+	dc.groups = groups
+	dc.mu.Unlock()
+
+	return groups, nil
+}
+
+func (dc *discoveryCache) ServerResourcesForGroupVersion(groupVersion string) (*k8smetav1.APIResourceList, error) {
+	dc.mu.Lock()
+	resources, ok := dc.resources[groupVersion]
+	dc.mu.Unlock()
+
+	if ok {
+		return resources, nil
+	}
+
+	filename := dc.filename(groupVersion, "serverresources.json")
+	resources = &k8smetav1.APIResourceList{}
+	if !dc.readFile(filename, resources) {
+		var err error
+		resources, err = dc.DiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			return resources, err
+		}
+		dc.writeFile(filename, resources)
+	}
+
+	dc.mu.Lock()
+	dc.resources[groupVersion] = resources
+	dc.mu.Unlock()
+
+	return resources, nil
+}
+
+func (dc *discoveryCache) ServerGroupsAndResources() ([]*k8smetav1.APIGroup, []*k8smetav1.APIResourceList, error) {
+	return discovery.ServerGroupsAndResources(dc)
+}
+
+func (dc *discoveryCache) ServerPreferredResources() ([]*k8smetav1.APIResourceList, error) {
+	return discovery.ServerPreferredResources(dc)
+}
+
+func (dc *discoveryCache) ServerPreferredNamespacedResources() ([]*k8smetav1.APIResourceList, error) {
+	return discovery.ServerPreferredNamespacedResources(dc)
+}
+
+func (dc *discoveryCache) WithLegacy() discovery.DiscoveryInterface {
+	return dc
+}
+
+func (dc *discoveryCache) Fresh() bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	return dc.fresh
+}
+
+// invalidateGroupVersion marks the list of groups and groupVersion
+// to be re-discovered on the next call to Invalidate
+func (dc *discoveryCache) invalidateGroupVersion(groupVersion string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.stale[groupVersion] = true
+}
+
+// Invalidate only re-discovers the group versions marked using
+// invalidateGroupVersion, if any, and everything otherwise.
+func (dc *discoveryCache) Invalidate() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.fresh = true
+	dc.groups = nil
+	dc.removeFile(dc.filename("servergroups.json"))
+
+	if len(dc.stale) > 0 {
+		for gv := range dc.stale {
+			delete(dc.resources, gv)
+			dc.removeFile(dc.filename(gv, "serverresources.json"))
+		}
+		dc.stale = make(map[string]bool)
+		return
+	}
+
+	dc.resources = make(map[string]*k8smetav1.APIResourceList)
+	dc.invalidatedAt = time.Now()
+}
+
+func (dc *discoveryCache) filename(elem ...string) string {
+	if dc.dir == "" {
+		return ""
+	}
+
+	return filepath.Join(append([]string{dc.dir}, elem...)...)
+}
+
+// readFile returns true, if filename was decoded into
+// obj and is neither older than the ttl nor invalidated
+func (dc *discoveryCache) readFile(filename string, obj interface{}) bool {
+	if filename == "" {
+		return false
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false
+	}
+
+	dc.mu.Lock()
+	invalidatedAt := dc.invalidatedAt
+	dc.mu.Unlock()
+
+	if info.ModTime().Before(invalidatedAt) || time.Now().After(info.ModTime().Add(dc.ttl)) {
+		return false
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		return false
+	}
+
+	dc.mu.Lock()
+	dc.fresh = false
+	dc.mu.Unlock()
+
+	return true
+}
+
+// writeFile writes obj to filename, errors are ignored,
+// because the cache is only an optimization
+func (dc *discoveryCache) writeFile(filename string, obj interface{}) {
+	if filename == "" {
+		return
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+		return
+	}
+
+	// write to a temporary file and rename it, so concurrent
+	// provider processes never read partially written files
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (dc *discoveryCache) removeFile(filename string) {
+	if filename == "" {
+		return
+	}
+
+	os.Remove(filename)
+}
@@ -0,0 +1,138 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// countingDiscovery counts the requests made to the fake discovery
+type countingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	groups    int
+	resources map[string]int
+}
+
+func newCountingDiscovery(resources ...*k8smetav1.APIResourceList) *countingDiscovery {
+	return &countingDiscovery{
+		FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}},
+		resources:     make(map[string]int),
+	}
+}
+
+func (cd *countingDiscovery) ServerGroups() (*k8smetav1.APIGroupList, error) {
+	cd.groups++
+	return cd.FakeDiscovery.ServerGroups()
+}
+
+func (cd *countingDiscovery) ServerResourcesForGroupVersion(gv string) (*k8smetav1.APIResourceList, error) {
+	cd.resources[gv]++
+	return cd.FakeDiscovery.ServerResourcesForGroupVersion(gv)
+}
+
+var testDiscoveryCoreV1 = &k8smetav1.APIResourceList{
+	GroupVersion: "v1",
+	APIResources: []k8smetav1.APIResource{
+		{Name: "namespaces", Kind: "Namespace", Verbs: []string{"get"}},
+	},
+}
+
+var testDiscoveryAppsV1 = &k8smetav1.APIResourceList{
+	GroupVersion: "apps/v1",
+	APIResources: []k8smetav1.APIResource{
+		{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"get"}},
+	},
+}
+
+func TestDiscoveryCacheInvalidateGroupVersion(t *testing.T) {
+	fake := newCountingDiscovery(testDiscoveryCoreV1, testDiscoveryAppsV1)
+	cache := newDiscoveryCache(fake, "", time.Hour)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cache)
+
+	_, err := mapper.RESTMapping(k8sschema.GroupKind{Kind: "Namespace"}, "v1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, fake.groups)
+	assert.Equal(t, 1, fake.resources["v1"])
+	assert.Equal(t, 1, fake.resources["apps/v1"])
+
+	// a CRD in an existing group version is created
+	crd := k8smetav1.APIResource{Name: "examples", Kind: "Example", Namespaced: true, Verbs: []string{"get"}}
+	fake.Resources[1].APIResources = append(fake.Resources[1].APIResources, crd)
+
+	gk := k8sschema.GroupKind{Group: "apps", Kind: "Example"}
+	_, err = mapper.RESTMapping(gk, "v1")
+	assert.True(t, k8smeta.IsNoMatchError(err))
+
+	cache.invalidateGroupVersion("apps/v1")
+	mapper.Reset()
+
+	_, err = mapper.RESTMapping(gk, "v1")
+	assert.Equal(t, nil, err)
+
+	// only the group list and the invalidated group version are discovered again
+	assert.Equal(t, 2, fake.groups)
+	assert.Equal(t, 1, fake.resources["v1"])
+	assert.Equal(t, 2, fake.resources["apps/v1"])
+
+	// without marked group versions everything is discovered again
+	mapper.Reset()
+	_, err = mapper.RESTMapping(gk, "v1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, fake.resources["v1"])
+	assert.Equal(t, 3, fake.resources["apps/v1"])
+}
+
+func TestDiscoveryCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+
+	fake := newCountingDiscovery(testDiscoveryCoreV1, testDiscoveryAppsV1)
+	cache := newDiscoveryCache(fake, dir, time.Hour)
+	_, _, err := cache.ServerGroupsAndResources()
+	assert.Equal(t, nil, err)
+	assert.True(t, cache.Fresh())
+
+	assert.FileExists(t, filepath.Join(dir, "servergroups.json"))
+	assert.FileExists(t, filepath.Join(dir, "apps", "v1", "serverresources.json"))
+
+	// a new provider process uses the files on disk
+	fake2 := newCountingDiscovery(testDiscoveryCoreV1, testDiscoveryAppsV1)
+	cache2 := newDiscoveryCache(fake2, dir, time.Hour)
+	_, resources, err := cache2.ServerGroupsAndResources()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(resources))
+	assert.Equal(t, 0, fake2.groups)
+	assert.Equal(t, 0, len(fake2.resources))
+	assert.False(t, cache2.Fresh())
+
+	// invalidating a group version removes its file
+	cache2.invalidateGroupVersion("apps/v1")
+	cache2.Invalidate()
+	assert.NoFileExists(t, filepath.Join(dir, "apps", "v1", "serverresources.json"))
+	assert.FileExists(t, filepath.Join(dir, "v1", "serverresources.json"))
+
+	// expired files are ignored
+	old := time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, nil, os.Chtimes(filepath.Join(dir, "v1", "serverresources.json"), old, old))
+
+	fake3 := newCountingDiscovery(testDiscoveryCoreV1, testDiscoveryAppsV1)
+	cache3 := newDiscoveryCache(fake3, dir, time.Hour)
+	_, err = cache3.ServerResourcesForGroupVersion("v1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, fake3.resources["v1"])
+}
+
+func TestGetDiscoveryCacheDir(t *testing.T) {
+	assert.Equal(t, "", getDiscoveryCacheDir("", "https://127.0.0.1:6443"))
+	assert.Equal(t, "", getDiscoveryCacheDir("/tmp/cache", ""))
+	assert.Equal(t, "/tmp/cache/127.0.0.1_6443", getDiscoveryCacheDir("/tmp/cache", "https://127.0.0.1:6443"))
+}
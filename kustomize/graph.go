@@ -0,0 +1,322 @@
+package kustomize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// pod spec locations of the built-in workload kinds
+var podSpecPaths = map[string][]string{
+	"_/Pod":                   {"spec"},
+	"_/ReplicationController": {"spec", "template", "spec"},
+	"apps/Deployment":         {"spec", "template", "spec"},
+	"apps/DaemonSet":          {"spec", "template", "spec"},
+	"apps/ReplicaSet":         {"spec", "template", "spec"},
+	"apps/StatefulSet":        {"spec", "template", "spec"},
+	"batch/Job":               {"spec", "template", "spec"},
+	"batch/CronJob":           {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// dependencyGraph returns a map of each id in manifests to the ids
+// of the other manifests it references and therefore depends on.
+// Only references to objects that are part of manifests are included.
+func dependencyGraph(manifests map[string]string) (graph map[string][]string, err error) {
+	objs := make(map[string]*k8sunstructured.Unstructured)
+	crds := make(map[string]string)
+	for id, m := range manifests {
+		km := &kManifest{}
+		err := km.load([]byte(m))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", id, err)
+		}
+		objs[id] = km.resource
+
+		if km.gvk().Group == "apiextensions.k8s.io" && km.gvk().Kind == "CustomResourceDefinition" {
+			group, _, _ := k8sunstructured.NestedString(km.resource.Object, "spec", "group")
+			kind, _, _ := k8sunstructured.NestedString(km.resource.Object, "spec", "names", "kind")
+			crds[fmt.Sprintf("%s/%s", emptyToUnderscore(group), kind)] = id
+		}
+	}
+
+	graph = make(map[string][]string)
+	for id, u := range objs {
+		deps := []string{}
+		seen := make(map[string]bool)
+		add := func(dep string) {
+			if _, ok := objs[dep]; !ok || dep == id || seen[dep] {
+				return
+			}
+			seen[dep] = true
+			deps = append(deps, dep)
+		}
+
+		gk := fmt.Sprintf("%s/%s", emptyToUnderscore(u.GroupVersionKind().Group), u.GetKind())
+		if crd, ok := crds[gk]; ok {
+			add(crd)
+		}
+
+		for _, ref := range objectReferences(u) {
+			add(ref.string())
+		}
+
+		sort.Strings(deps)
+		graph[id] = deps
+	}
+
+	return graph, nil
+}
+
+// objectReferences returns the ids of all objects u refers to
+func objectReferences(u *k8sunstructured.Unstructured) (refs []kManifestId) {
+	ns := u.GetNamespace()
+	gk := fmt.Sprintf("%s/%s", emptyToUnderscore(u.GroupVersionKind().Group), u.GetKind())
+
+	if ns != "" {
+		refs = append(refs, kManifestId{kind: "Namespace", name: ns})
+	}
+
+	if path, ok := podSpecPaths[gk]; ok {
+		podSpec, _, _ := k8sunstructured.NestedMap(u.Object, path...)
+		refs = append(refs, podSpecReferences(podSpec, ns)...)
+	}
+
+	switch gk {
+	case "_/Secret":
+		if sa, ok := u.GetAnnotations()["kubernetes.io/service-account.name"]; ok {
+			refs = append(refs, kManifestId{kind: "ServiceAccount", namespace: ns, name: sa})
+		}
+	case "rbac.authorization.k8s.io/RoleBinding", "rbac.authorization.k8s.io/ClusterRoleBinding":
+		kind, _, _ := k8sunstructured.NestedString(u.Object, "roleRef", "kind")
+		name, _, _ := k8sunstructured.NestedString(u.Object, "roleRef", "name")
+		switch kind {
+		case "Role":
+			refs = append(refs, kManifestId{group: "rbac.authorization.k8s.io", kind: kind, namespace: ns, name: name})
+		case "ClusterRole":
+			refs = append(refs, kManifestId{group: "rbac.authorization.k8s.io", kind: kind, name: name})
+		}
+
+		subjects, _, _ := k8sunstructured.NestedSlice(u.Object, "subjects")
+		for _, s := range subjects {
+			subject, ok := s.(map[string]interface{})
+			if !ok || subject["kind"] != "ServiceAccount" {
+				continue
+			}
+			sns := ns
+			if v, ok := subject["namespace"].(string); ok && v != "" {
+				sns = v
+			}
+			name, _ := subject["name"].(string)
+			refs = append(refs, kManifestId{kind: "ServiceAccount", namespace: sns, name: name})
+		}
+	case "admissionregistration.k8s.io/MutatingWebhookConfiguration", "admissionregistration.k8s.io/ValidatingWebhookConfiguration":
+		webhooks, _, _ := k8sunstructured.NestedSlice(u.Object, "webhooks")
+		for _, w := range webhooks {
+			webhook, ok := w.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sns, _, _ := k8sunstructured.NestedString(webhook, "clientConfig", "service", "namespace")
+			name, _, _ := k8sunstructured.NestedString(webhook, "clientConfig", "service", "name")
+			if name != "" {
+				refs = append(refs, kManifestId{kind: "Service", namespace: sns, name: name})
+			}
+		}
+	}
+
+	return refs
+}
+
+func podSpecReferences(podSpec map[string]interface{}, ns string) (refs []kManifestId) {
+	if podSpec == nil {
+		return refs
+	}
+
+	configMap := func(name string) {
+		if name != "" {
+			refs = append(refs, kManifestId{kind: "ConfigMap", namespace: ns, name: name})
+		}
+	}
+	secret := func(name string) {
+		if name != "" {
+			refs = append(refs, kManifestId{kind: "Secret", namespace: ns, name: name})
+		}
+	}
+
+	for _, f := range []string{"serviceAccountName", "serviceAccount"} {
+		if name, _, _ := k8sunstructured.NestedString(podSpec, f); name != "" {
+			refs = append(refs, kManifestId{kind: "ServiceAccount", namespace: ns, name: name})
+		}
+	}
+
+	for _, s := range nestedMaps(podSpec, "imagePullSecrets") {
+		name, _, _ := k8sunstructured.NestedString(s, "name")
+		secret(name)
+	}
+
+	for _, v := range nestedMaps(podSpec, "volumes") {
+		name, _, _ := k8sunstructured.NestedString(v, "configMap", "name")
+		configMap(name)
+		name, _, _ = k8sunstructured.NestedString(v, "secret", "secretName")
+		secret(name)
+
+		for _, p := range nestedMaps(v, "projected", "sources") {
+			name, _, _ := k8sunstructured.NestedString(p, "configMap", "name")
+			configMap(name)
+			name, _, _ = k8sunstructured.NestedString(p, "secret", "name")
+			secret(name)
+		}
+	}
+
+	containers := nestedMaps(podSpec, "initContainers")
+	containers = append(containers, nestedMaps(podSpec, "containers")...)
+	for _, c := range containers {
+		for _, e := range nestedMaps(c, "envFrom") {
+			name, _, _ := k8sunstructured.NestedString(e, "configMapRef", "name")
+			configMap(name)
+			name, _, _ = k8sunstructured.NestedString(e, "secretRef", "name")
+			secret(name)
+		}
+
+		for _, e := range nestedMaps(c, "env") {
+			name, _, _ := k8sunstructured.NestedString(e, "valueFrom", "configMapKeyRef", "name")
+			configMap(name)
+			name, _, _ = k8sunstructured.NestedString(e, "valueFrom", "secretKeyRef", "name")
+			secret(name)
+		}
+	}
+
+	return refs
+}
+
+func nestedMaps(obj map[string]interface{}, fields ...string) (out []map[string]interface{}) {
+	l, _, _ := k8sunstructured.NestedSlice(obj, fields...)
+	for _, i := range l {
+		if m, ok := i.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// reverseDependencyGraph returns a graph with all edges inverted,
+// walking it visits dependents before their dependencies, e.g. for deletes
+func reverseDependencyGraph(graph map[string][]string) map[string][]string {
+	rev := make(map[string][]string)
+	for id, deps := range graph {
+		if _, ok := rev[id]; !ok {
+			rev[id] = []string{}
+		}
+		for _, dep := range deps {
+			rev[dep] = append(rev[dep], id)
+		}
+	}
+
+	for id := range rev {
+		sort.Strings(rev[id])
+	}
+
+	return rev
+}
+
+// walkDependencyGraph calls fn for every id in graph after fn returned
+// without error for all of its dependencies. At most parallelism calls
+// run at the same time. After the first error no new calls are started.
+func walkDependencyGraph(graph map[string][]string, parallelism int, fn func(id string) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for id, deps := range graph {
+		pending[id] = 0
+		for _, dep := range deps {
+			if _, ok := graph[dep]; !ok {
+				continue
+			}
+			pending[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	ready := []string{}
+	for id, n := range pending {
+		if n == 0 {
+			ready = append(ready, id)
+		}
+	}
+	sort.Strings(ready)
+
+	type result struct {
+		id  string
+		err error
+	}
+	results := make(chan result)
+	var wg sync.WaitGroup
+
+	errs := []string{}
+	done := 0
+	running := 0
+	for {
+		for len(errs) == 0 && running < parallelism && len(ready) > 0 {
+			id := ready[0]
+			ready = ready[1:]
+			running++
+
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				results <- result{id, fn(id)}
+			}(id)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		done++
+
+		if r.err != nil {
+			errs = append(errs, r.err.Error())
+			continue
+		}
+
+		next := []string{}
+		for _, id := range dependents[r.id] {
+			pending[id]--
+			if pending[id] == 0 {
+				next = append(next, id)
+			}
+		}
+		sort.Strings(next)
+		ready = append(ready, next...)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	if done != len(graph) {
+		return fmt.Errorf("dependency cycle between: %s", strings.Join(blockedIds(pending), ", "))
+	}
+
+	return nil
+}
+
+func blockedIds(pending map[string]int) (ids []string) {
+	for id, n := range pending {
+		if n > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
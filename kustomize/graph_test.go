@@ -0,0 +1,132 @@
+package kustomize
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var graphTestManifests = map[string]string{
+	"_/Namespace/_/test":        `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`,
+	"_/ServiceAccount/test/app": `{"apiVersion":"v1","kind":"ServiceAccount","metadata":{"name":"app","namespace":"test"}}`,
+	"_/ConfigMap/test/config":   `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"test"}}`,
+	"_/Secret/test/creds":       `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"creds","namespace":"test"}}`,
+	"apps/Deployment/test/app": `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app","namespace":"test"},"spec":{"template":{"spec":{
+		"serviceAccountName":"app",
+		"volumes":[{"name":"config","configMap":{"name":"config"}}],
+		"containers":[{"name":"app","env":[{"name":"PASSWORD","valueFrom":{"secretKeyRef":{"name":"creds","key":"password"}}}]}]
+	}}}}`,
+	"rbac.authorization.k8s.io/Role/test/app": `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"app","namespace":"test"}}`,
+	"rbac.authorization.k8s.io/RoleBinding/test/app": `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"RoleBinding","metadata":{"name":"app","namespace":"test"},
+		"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"Role","name":"app"},
+		"subjects":[{"kind":"ServiceAccount","name":"app"}]}`,
+	"apiextensions.k8s.io/CustomResourceDefinition/_/clusteredobjects.test.example.com": `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"clusteredobjects.test.example.com"},
+		"spec":{"group":"test.example.com","names":{"kind":"Clusteredobject"}}}`,
+	"test.example.com/Clusteredobject/_/test": `{"apiVersion":"test.example.com/v1alpha1","kind":"Clusteredobject","metadata":{"name":"test"}}`,
+}
+
+func TestDependencyGraph(t *testing.T) {
+	graph, err := dependencyGraph(graphTestManifests)
+	assert.Equal(t, nil, err)
+
+	assert.ElementsMatch(t, []string{}, graph["_/Namespace/_/test"])
+	assert.ElementsMatch(t, []string{"_/Namespace/_/test"}, graph["_/ConfigMap/test/config"])
+	assert.ElementsMatch(t, []string{
+		"_/Namespace/_/test",
+		"_/ServiceAccount/test/app",
+		"_/ConfigMap/test/config",
+		"_/Secret/test/creds",
+	}, graph["apps/Deployment/test/app"])
+	assert.ElementsMatch(t, []string{
+		"_/Namespace/_/test",
+		"_/ServiceAccount/test/app",
+		"rbac.authorization.k8s.io/Role/test/app",
+	}, graph["rbac.authorization.k8s.io/RoleBinding/test/app"])
+	assert.ElementsMatch(t, []string{
+		"apiextensions.k8s.io/CustomResourceDefinition/_/clusteredobjects.test.example.com",
+	}, graph["test.example.com/Clusteredobject/_/test"])
+}
+
+func TestDependencyGraphIgnoresMissingReferences(t *testing.T) {
+	graph, err := dependencyGraph(map[string]string{
+		"apps/Deployment/test/app": graphTestManifests["apps/Deployment/test/app"],
+	})
+	assert.Equal(t, nil, err)
+	assert.ElementsMatch(t, []string{}, graph["apps/Deployment/test/app"])
+}
+
+func TestWalkDependencyGraph(t *testing.T) {
+	graph, err := dependencyGraph(graphTestManifests)
+	assert.Equal(t, nil, err)
+
+	mu := sync.Mutex{}
+	visited := make(map[string]bool)
+	err = walkDependencyGraph(graph, 3, func(id string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, dep := range graph[id] {
+			assert.True(t, visited[dep], "%q visited before %q", id, dep)
+		}
+		visited[id] = true
+
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, len(graph), len(visited))
+}
+
+func TestWalkReverseDependencyGraph(t *testing.T) {
+	graph, err := dependencyGraph(graphTestManifests)
+	assert.Equal(t, nil, err)
+
+	mu := sync.Mutex{}
+	visited := make(map[string]bool)
+	err = walkDependencyGraph(reverseDependencyGraph(graph), 3, func(id string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, dep := range graph[id] {
+			assert.False(t, visited[dep], "%q visited after %q", id, dep)
+		}
+		visited[id] = true
+
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, len(graph), len(visited))
+}
+
+func TestWalkDependencyGraphStopsOnError(t *testing.T) {
+	graph := map[string][]string{
+		"a": {},
+		"b": {"a"},
+		"c": {"b"},
+	}
+
+	visited := []string{}
+	err := walkDependencyGraph(graph, 1, func(id string) error {
+		visited = append(visited, id)
+		if id == "b" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, []string{"a", "b"}, visited)
+}
+
+func TestWalkDependencyGraphCycle(t *testing.T) {
+	graph := map[string][]string{
+		"a": {},
+		"b": {"a", "c"},
+		"c": {"b"},
+	}
+
+	err := walkDependencyGraph(graph, 2, func(id string) error {
+		return nil
+	})
+	assert.EqualError(t, err, "dependency cycle between: b, c")
+}
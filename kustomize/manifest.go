@@ -2,6 +2,7 @@ package kustomize
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -28,6 +29,15 @@ var waitRefreshFunctions = map[string]waitRefreshFunction{
 	"apps/StatefulSet": waitStatefulSetRefresh,
 }
 
+// kinds that roll out new pods when their pod template changes
+var restartableKinds = map[string]bool{
+	"apps/Deployment":  true,
+	"apps/DaemonSet":   true,
+	"apps/StatefulSet": true,
+}
+
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
 type kManifestId struct {
 	group     string
 	kind      string
@@ -89,6 +99,22 @@ type kManifest struct {
 	mapper   *restmapper.DeferredDiscoveryRESTMapper
 	client   k8sdynamic.Interface
 	json     []byte
+
+	// namespace for namespaced objects without one
+	defaultNamespace string
+
+	// discovery cache of mapper, if it supports
+	// invalidating a single group version
+	discovery *discoveryCache
+
+	// cache to refresh state from, if enabled
+	readCache *readCache
+
+	// cancels API calls and waits, e.g. on interrupt
+	ctx context.Context
+
+	// retries API calls failing with transient errors, if set
+	retry *retryPolicy
 }
 
 func newKManifest(mapper *restmapper.DeferredDiscoveryRESTMapper, client k8sdynamic.Interface) *kManifest {
@@ -98,6 +124,22 @@ func newKManifest(mapper *restmapper.DeferredDiscoveryRESTMapper, client k8sdyna
 	}
 }
 
+// context returns the context of km, or an empty
+// context for kManifests not created by a Config
+func (km *kManifest) context() context.Context {
+	ctx := km.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// attribute API server warnings to the object
+	if km.resource != nil {
+		ctx = withWarningSource(ctx, km.id().string())
+	}
+
+	return ctx
+}
+
 func (km *kManifest) load(body []byte) error {
 	obj, err := k8sruntime.Decode(k8sunstructured.UnstructuredJSONScheme, body)
 	if err != nil {
@@ -127,6 +169,59 @@ func (km *kManifest) mappings() (m []*k8smeta.RESTMapping, err error) {
 	return km.mapper.RESTMappings(km.gvk().GroupKind())
 }
 
+// servedAPIVersion returns the preferred version of the GroupKind of
+// km, if the API server no longer serves the version of km, e.g. after
+// a cluster upgrade removed it, and an empty string otherwise
+func (km *kManifest) servedAPIVersion() string {
+	_, err := km.mapping()
+	if err == nil || !k8smeta.IsNoMatchError(err) {
+		// other errors are returned by the following API call
+		return ""
+	}
+
+	mappings, err := km.mappings()
+	if err != nil || len(mappings) == 0 {
+		// no version of the kind is served, e.g. CRD not created yet
+		return ""
+	}
+
+	return mappings[0].GroupVersionKind.GroupVersion().String()
+}
+
+// checkAPIVersionServed returns an error, if the API server no longer
+// serves the version of km. Only objects in the state are migrated,
+// manifests using a removed version have to be updated.
+func (km *kManifest) checkAPIVersionServed() error {
+	if to := km.servedAPIVersion(); to != "" {
+		return km.fmtErr(fmt.Errorf("apiVersion %q is no longer served, update the manifest to %q", km.resource.GetAPIVersion(), to))
+	}
+
+	return nil
+}
+
+// migrateAPIVersion switches km to the preferred version of its
+// GroupKind, if the API server no longer serves the version of km,
+// e.g. after a cluster upgrade removed it. It returns the previous
+// apiVersion if km was migrated, and an empty string otherwise.
+func (km *kManifest) migrateAPIVersion() (from string, err error) {
+	to := km.servedAPIVersion()
+	if to == "" {
+		return "", nil
+	}
+
+	from = km.resource.GetAPIVersion()
+
+	km.resource.SetAPIVersion(to)
+	km.json, err = km.resource.MarshalJSON()
+	if err != nil {
+		return "", km.fmtErr(fmt.Errorf("json error: %s", err))
+	}
+
+	log.Printf("[WARN] %q: apiVersion %q is no longer served, migrating to %q", km.id().string(), from, to)
+
+	return from, nil
+}
+
 func (km *kManifest) namespace() string {
 	return km.id().namespace
 }
@@ -158,23 +253,44 @@ func (km *kManifest) id() (id kManifestId) {
 }
 
 func (km *kManifest) api() (api k8sdynamic.ResourceInterface, err error) {
-	gvr, err := km.gvr()
+	api, _, _, err = km.resourceClient()
+	return api, err
+}
+
+// resourceClient returns the client for the resource and namespace of
+// km, the resource and the namespace, using a single mapper lookup
+func (km *kManifest) resourceClient() (api k8sdynamic.ResourceInterface, gvr k8sschema.GroupVersionResource, namespace string, err error) {
+	m, err := km.mapping()
 	if err != nil {
-		return api, km.fmtErr(fmt.Errorf("api error: %s", err))
+		return api, gvr, namespace, km.fmtErr(fmt.Errorf("api error: %s", err))
 	}
+	gvr = m.Resource
 
 	api = km.client.Resource(gvr)
 
-	isNamespaced, err := km.isNamespaced()
-	if err != nil {
-		return api, km.fmtErr(fmt.Errorf("api error: %s", err))
+	if m.Scope.Name() == k8smeta.RESTScopeNameNamespace {
+		namespace = km.namespace()
+		if namespace == "" {
+			namespace = km.defaultNamespace
+		}
+		api = km.client.Resource(gvr).Namespace(namespace)
 	}
 
-	if isNamespaced {
-		api = km.client.Resource(gvr).Namespace(km.namespace())
+	return api, gvr, namespace, nil
+}
+
+// markWritten marks km as changed in the read cache, unless opts is a dry-run
+func (km *kManifest) markWritten(dryRun []string) {
+	if km.readCache == nil || len(dryRun) > 0 {
+		return
 	}
 
-	return api, nil
+	_, gvr, namespace, err := km.resourceClient()
+	if err != nil {
+		return
+	}
+
+	km.readCache.written(gvr, namespace, km.name())
 }
 
 func (km *kManifest) apiGet(opts k8smetav1.GetOptions) (resp *k8sunstructured.Unstructured, err error) {
@@ -183,7 +299,32 @@ func (km *kManifest) apiGet(opts k8smetav1.GetOptions) (resp *k8sunstructured.Un
 		return resp, km.fmtErr(fmt.Errorf("get failed: %s", err))
 	}
 
-	return api.Get(context.TODO(), km.name(), opts)
+	err = km.retry.do(km.context(), "get "+km.id().string(), func() (err error) {
+		resp, err = api.Get(km.context(), km.name(), opts)
+		return err
+	})
+
+	return resp, err
+}
+
+// apiGetCached gets km from the read cache, if enabled, otherwise it
+// is the same as apiGet. Only used to refresh state, not for patches.
+func (km *kManifest) apiGetCached() (resp *k8sunstructured.Unstructured, err error) {
+	if km.readCache == nil {
+		return km.apiGet(k8smetav1.GetOptions{})
+	}
+
+	api, gvr, namespace, err := km.resourceClient()
+	if err != nil {
+		return resp, km.fmtErr(fmt.Errorf("get failed: %s", err))
+	}
+
+	err = km.retry.do(km.context(), "get "+km.id().string(), func() (err error) {
+		resp, err = km.readCache.get(km.context(), api, gvr, namespace, km.name())
+		return err
+	})
+
+	return resp, err
 }
 
 func (km *kManifest) apiCreate(opts k8smetav1.CreateOptions) (resp *k8sunstructured.Unstructured, err error) {
@@ -192,7 +333,40 @@ func (km *kManifest) apiCreate(opts k8smetav1.CreateOptions) (resp *k8sunstructu
 		return resp, km.fmtErr(fmt.Errorf("create failed: %s", err))
 	}
 
-	return api.Create(context.TODO(), km.resource, opts)
+	defer km.markWritten(opts.DryRun)
+
+	attempt := 0
+	err = km.retry.do(km.context(), "create "+km.id().string(), func() (err error) {
+		attempt++
+		resp, err = api.Create(km.context(), km.resource, opts)
+		if attempt > 1 && k8serrors.IsAlreadyExists(err) {
+			// creates are not idempotent, a previous attempt
+			// that failed with a timeout may have succeeded
+			resp, err = km.getCreatedByPreviousAttempt(api, err)
+		}
+		return err
+	})
+
+	return resp, err
+}
+
+// getCreatedByPreviousAttempt returns the live object, if it has the
+// lastAppliedConfig of km, and the AlreadyExists error otherwise
+func (km *kManifest) getCreatedByPreviousAttempt(api k8sdynamic.ResourceInterface, alreadyExists error) (*k8sunstructured.Unstructured, error) {
+	live, err := api.Get(km.context(), km.name(), k8smetav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range []string{lastAppliedConfigAnnotation, gzipLastAppliedConfigAnnotation} {
+		if live.GetAnnotations()[k] != km.resource.GetAnnotations()[k] {
+			return nil, alreadyExists
+		}
+	}
+
+	log.Printf("[DEBUG] %q: created by a previous attempt", km.id().string())
+
+	return live, nil
 }
 
 func (km *kManifest) apiDelete(opts k8smetav1.DeleteOptions) (err error) {
@@ -201,7 +375,11 @@ func (km *kManifest) apiDelete(opts k8smetav1.DeleteOptions) (err error) {
 		return km.fmtErr(fmt.Errorf("delete failed: %s", err))
 	}
 
-	return api.Delete(context.TODO(), km.name(), opts)
+	defer km.markWritten(opts.DryRun)
+
+	return km.retry.do(km.context(), "delete "+km.id().string(), func() error {
+		return api.Delete(km.context(), km.name(), opts)
+	})
 }
 
 func (km *kManifest) apiPreparePatch(kmo *kManifest, currAllowNotFound bool) (pt k8stypes.PatchType, p []byte, err error) {
@@ -234,7 +412,48 @@ func (km *kManifest) apiPatch(pt k8stypes.PatchType, p []byte, opts k8smetav1.Pa
 		return resp, km.fmtErr(fmt.Errorf("patch failed: %s", err))
 	}
 
-	return api.Patch(context.TODO(), km.name(), pt, p, opts)
+	defer km.markWritten(opts.DryRun)
+
+	err = km.retry.do(km.context(), "patch "+km.id().string(), func() (err error) {
+		resp, err = api.Patch(km.context(), km.name(), pt, p, opts)
+		return err
+	})
+
+	return resp, err
+}
+
+func (km *kManifest) isRestartable() bool {
+	return restartableKinds[fmt.Sprintf("%s/%s", km.gvk().Group, km.gvk().Kind)]
+}
+
+// apiRestart patches the pod template's restartedAt annotation,
+// the same way kubectl rollout restart does. The patch does not
+// change the lastAppliedConfig, so the annotation is not drift.
+func (km *kManifest) apiRestart(opts k8smetav1.PatchOptions) (resp *k8sunstructured.Unstructured, err error) {
+	p, err := addRestartedAt(nil)
+	if err != nil {
+		return resp, km.fmtErr(fmt.Errorf("restart failed: %s", err))
+	}
+
+	return km.apiPatch(k8stypes.StrategicMergePatchType, p, opts)
+}
+
+// addRestartedAt adds the pod template's restartedAt annotation to
+// the patch p, so a change and a restart are rolled out together
+func addRestartedAt(p []byte) ([]byte, error) {
+	patch := make(map[string]interface{})
+	if len(p) > 0 {
+		if err := json.Unmarshal(p, &patch); err != nil {
+			return nil, err
+		}
+	}
+
+	err := k8sunstructured.SetNestedField(patch, time.Now().Format(time.RFC3339), "spec", "template", "metadata", "annotations", restartedAtAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(patch)
 }
 
 func parseResourceData(km *kManifest, d string) (err error) {
@@ -254,6 +473,10 @@ func (km *kManifest) getNamespaceManifest() (kns *kManifest, namespaced bool) {
 	}
 
 	kns = newKManifest(km.mapper, km.client)
+	kns.discovery = km.discovery
+	kns.readCache = km.readCache
+	kns.ctx = km.ctx
+	kns.retry = km.retry
 
 	kns.resource = kns.resource.NewEmptyInstance().(*k8sunstructured.Unstructured)
 
@@ -279,6 +502,10 @@ func (km *kManifest) waitKind(t time.Duration) error {
 				if k8smeta.IsNoMatchError(err) {
 					// if not found, reset mapper cache
 					// before trying again (required for CRDs)
+					// only the kind's group version is re-discovered
+					if km.discovery != nil {
+						km.discovery.invalidateGroupVersion(km.gvk().GroupVersion().String())
+					}
 					km.mapper.Reset()
 					return nil, "pending", nil
 				}
@@ -289,7 +516,7 @@ func (km *kManifest) waitKind(t time.Duration) error {
 		},
 	}
 
-	_, err := stateConf.WaitForState()
+	_, err := stateConf.WaitForStateContext(km.context())
 	if err != nil {
 		return km.fmtErr(fmt.Errorf("timed out waiting for: %q: %s", km.gvk().String(), err))
 	}
@@ -330,7 +557,7 @@ func (km *kManifest) waitNamespace(t time.Duration) error {
 		},
 	}
 
-	_, err = stateConf.WaitForState()
+	_, err = stateConf.WaitForStateContext(km.context())
 	if err != nil {
 		return km.fmtErr(fmt.Errorf("timed out waiting for: %q: %s", kns.id().string(), err))
 	}
@@ -356,7 +583,7 @@ func (km *kManifest) waitDeleted(t time.Duration) error {
 		},
 	}
 
-	_, err := stateConf.WaitForState()
+	_, err := stateConf.WaitForStateContext(km.context())
 	if err != nil {
 		return km.fmtErr(fmt.Errorf("timed out deleting: %s", err))
 	}
@@ -477,7 +704,7 @@ func (km *kManifest) waitCreatedOrUpdated(t time.Duration) error {
 			},
 		}
 
-		_, err := stateConf.WaitForState()
+		_, err := stateConf.WaitForStateContext(km.context())
 		if err != nil {
 			return km.fmtErr(fmt.Errorf("timed out creating/updating %s %s/%s: %s", gvk.Kind, km.namespace(), km.name(), err))
 		}
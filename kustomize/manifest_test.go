@@ -1,9 +1,14 @@
 package kustomize
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/restmapper"
 )
 
 func TestKManifestLoad(t *testing.T) {
@@ -32,3 +37,88 @@ func TestKManifestLoadErr(t *testing.T) {
 
 	assert.NotEqual(t, nil, err)
 }
+
+func TestKManifestMigrateAPIVersion(t *testing.T) {
+	fake := newCountingDiscovery(&k8smetav1.APIResourceList{
+		GroupVersion: "policy/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "poddisruptionbudgets", Kind: "PodDisruptionBudget", Namespaced: true, Verbs: []string{"get"}},
+		},
+	})
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(newDiscoveryCache(fake, "", 0))
+
+	// removed version is migrated to the served version
+	km := newKManifest(mapper, nil)
+	err := km.load([]byte(`{"apiVersion": "policy/v1beta1", "kind": "PodDisruptionBudget", "metadata": {"name": "test", "namespace": "test"}}`))
+	assert.Equal(t, nil, err)
+
+	from, err := km.migrateAPIVersion()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "policy/v1beta1", from)
+	assert.Equal(t, "policy/v1", km.resource.GetAPIVersion())
+	assert.Contains(t, string(km.json), `"apiVersion":"policy/v1"`)
+
+	// served version is kept
+	from, err = km.migrateAPIVersion()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", from)
+
+	// kinds not served in any version are kept
+	km = newKManifest(mapper, nil)
+	err = km.load([]byte(`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}}`))
+	assert.Equal(t, nil, err)
+
+	from, err = km.migrateAPIVersion()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", from)
+	assert.Equal(t, "example.com/v1", km.resource.GetAPIVersion())
+}
+
+func TestKManifestCheckAPIVersionServed(t *testing.T) {
+	fake := newCountingDiscovery(&k8smetav1.APIResourceList{
+		GroupVersion: "networking.k8s.io/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true, Verbs: []string{"get"}},
+		},
+	})
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(newDiscoveryCache(fake, "", 0))
+
+	// manifests using a removed version are not migrated
+	km := newKManifest(mapper, nil)
+	err := km.load([]byte(`{"apiVersion": "networking.k8s.io/v1beta1", "kind": "Ingress", "metadata": {"name": "test", "namespace": "test"}}`))
+	assert.Equal(t, nil, err)
+
+	err = km.checkAPIVersionServed()
+	assert.EqualError(t, err, `"networking.k8s.io/Ingress/test/test": apiVersion "networking.k8s.io/v1beta1" is no longer served, update the manifest to "networking.k8s.io/v1"`)
+	assert.Equal(t, "networking.k8s.io/v1beta1", km.resource.GetAPIVersion())
+
+	// served versions and kinds not served in any version are valid
+	for _, manifest := range []string{
+		`{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "test", "namespace": "test"}}`,
+		`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}}`,
+	} {
+		km = newKManifest(mapper, nil)
+		err = km.load([]byte(manifest))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, km.checkAPIVersionServed())
+	}
+}
+
+func TestAddRestartedAt(t *testing.T) {
+	p, err := addRestartedAt([]byte(`{"spec":{"template":{"spec":{"containers":[{"image":"nginx:2","name":"nginx"}]}}}}`))
+	assert.Equal(t, nil, err)
+
+	patch := make(map[string]interface{})
+	err = json.Unmarshal(p, &patch)
+	assert.Equal(t, nil, err)
+
+	// the change and the restart are one patch
+	containers, _, _ := k8sunstructured.NestedSlice(patch, "spec", "template", "spec", "containers")
+	assert.Equal(t, 1, len(containers))
+	restartedAt, _, _ := k8sunstructured.NestedString(patch, "spec", "template", "metadata", "annotations", restartedAtAnnotation)
+	assert.NotEqual(t, "", restartedAt)
+
+	p, err = addRestartedAt(nil)
+	assert.Equal(t, nil, err)
+	assert.Contains(t, string(p), restartedAtAnnotation)
+}
@@ -0,0 +1,214 @@
+package kustomize
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quantity paths of pod specs, * matches any map key and [] all list items
+var podSpecQuantityPaths = [][]string{
+	{"containers", "[]", "resources", "limits", "*"},
+	{"containers", "[]", "resources", "requests", "*"},
+	{"initContainers", "[]", "resources", "limits", "*"},
+	{"initContainers", "[]", "resources", "requests", "*"},
+	{"ephemeralContainers", "[]", "resources", "limits", "*"},
+	{"ephemeralContainers", "[]", "resources", "requests", "*"},
+	{"overhead", "*"},
+	{"volumes", "[]", "emptyDir", "sizeLimit"},
+	{"volumes", "[]", "ephemeral", "volumeClaimTemplate", "spec", "resources", "limits", "*"},
+	{"volumes", "[]", "ephemeral", "volumeClaimTemplate", "spec", "resources", "requests", "*"},
+}
+
+// quantity paths of the other built-in kinds
+var kindQuantityPaths = map[string][][]string{
+	"_/PersistentVolume": {
+		{"spec", "capacity", "*"},
+	},
+	"_/PersistentVolumeClaim": {
+		{"spec", "resources", "limits", "*"},
+		{"spec", "resources", "requests", "*"},
+	},
+	"_/ResourceQuota": {
+		{"spec", "hard", "*"},
+	},
+	"_/LimitRange": {
+		{"spec", "limits", "[]", "default", "*"},
+		{"spec", "limits", "[]", "defaultRequest", "*"},
+		{"spec", "limits", "[]", "max", "*"},
+		{"spec", "limits", "[]", "min", "*"},
+		{"spec", "limits", "[]", "maxLimitRequestRatio", "*"},
+	},
+	"apps/StatefulSet": {
+		{"spec", "volumeClaimTemplates", "[]", "spec", "resources", "limits", "*"},
+		{"spec", "volumeClaimTemplates", "[]", "spec", "resources", "requests", "*"},
+	},
+}
+
+// fields where an empty map differs from an omitted one, e.g. an
+// empty namespaceSelector selects all namespaces, an omitted one none.
+// Maps that are empty after normalization, e.g. {matchLabels: {}},
+// are the same as empty ones.
+var emptyMapFields = map[string]bool{
+	"emptyDir":          true,
+	"namespaceSelector": true,
+	"podSelector":       true,
+	"selector":          true,
+}
+
+// manifestsEqual returns true if the manifests a and b are semantically
+// equal, e.g. differ only in key order, empty or omitted fields, or
+// the notation of quantities. False if either one can not be parsed.
+func manifestsEqual(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	na, err := normalizeManifest(a)
+	if err != nil {
+		return false
+	}
+
+	nb, err := normalizeManifest(b)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(na, nb)
+}
+
+// normalizeManifest parses manifest and returns it normalized for
+// comparison, Kubernetes-aware for the built-in kinds, generic for others
+func normalizeManifest(manifest string) (map[string]interface{}, error) {
+	km := &kManifest{}
+	if err := km.load([]byte(manifest)); err != nil {
+		return nil, err
+	}
+	obj := km.resource.Object
+
+	gk := fmt.Sprintf("%s/%s", emptyToUnderscore(km.gvk().Group), km.gvk().Kind)
+
+	paths := kindQuantityPaths[gk]
+	if podSpecPath, ok := podSpecPaths[gk]; ok {
+		for _, p := range podSpecQuantityPaths {
+			paths = append(paths, append(append([]string{}, podSpecPath...), p...))
+		}
+	}
+	for _, p := range paths {
+		normalizeAtPath(obj, p, normalizeQuantity)
+	}
+
+	normalized, _ := normalizeValue(obj).(map[string]interface{})
+	if normalized == nil {
+		normalized = make(map[string]interface{})
+	}
+
+	return normalized, nil
+}
+
+// normalizeValue removes null values and empty maps and lists,
+// and converts floats without a fraction to integers
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for k, c := range t {
+			n := normalizeValue(c)
+			if _, ok := c.(map[string]interface{}); ok && n == nil && emptyMapFields[k] {
+				out[k] = map[string]interface{}{}
+				continue
+			}
+			if n != nil {
+				out[k] = n
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		out := make([]interface{}, len(t))
+		for i, c := range t {
+			// keep the position of empty list items
+			out[i] = normalizeValue(c)
+		}
+		return out
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < math.MaxInt64 {
+			return int64(t)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// normalizeAtPath calls fn for every value at path in obj
+func normalizeAtPath(obj interface{}, path []string, fn func(interface{}) interface{}) {
+	if len(path) == 0 {
+		return
+	}
+
+	switch t := obj.(type) {
+	case map[string]interface{}:
+		keys := []string{path[0]}
+		if path[0] == "*" {
+			keys = []string{}
+			for k := range t {
+				keys = append(keys, k)
+			}
+		}
+
+		for _, k := range keys {
+			c, ok := t[k]
+			if !ok {
+				continue
+			}
+			if len(path) == 1 {
+				t[k] = fn(c)
+				continue
+			}
+			normalizeAtPath(c, path[1:], fn)
+		}
+	case []interface{}:
+		if path[0] != "[]" {
+			return
+		}
+		for i, c := range t {
+			if len(path) == 1 {
+				t[i] = fn(c)
+				continue
+			}
+			normalizeAtPath(c, path[1:], fn)
+		}
+	}
+}
+
+// normalizeQuantity returns the canonical form of a quantity,
+// e.g. "1" for "1000m", or v unchanged if it's not a quantity
+func normalizeQuantity(v interface{}) interface{} {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case int64:
+		s = strconv.FormatInt(t, 10)
+	case float64:
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return v
+	}
+
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return v
+	}
+
+	return q.String()
+}
@@ -0,0 +1,124 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestsEqual(t *testing.T) {
+	testCases := []struct {
+		a     string
+		b     string
+		equal bool
+	}{
+		// key order
+		{
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}, "data": {"a": "1", "b": "2"}}`,
+			`{"kind": "ConfigMap", "apiVersion": "v1", "data": {"b": "2", "a": "1"}, "metadata": {"namespace": "test", "name": "test"}}`,
+			true,
+		},
+		// empty maps, lists and nulls vs omitted keys
+		{
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "labels": {}, "annotations": null}, "data": {}}`,
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}}`,
+			true,
+		},
+		{
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}, "data": {"a": ""}}`,
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}}`,
+			false,
+		},
+		// quantities of known kinds
+		{
+			`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "test"}, "spec": {"template": {"spec": {"containers": [{"name": "test", "resources": {"limits": {"cpu": "1000m", "memory": "1024Mi"}, "requests": {"cpu": 0.5}}}]}}}}`,
+			`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "test"}, "spec": {"template": {"spec": {"containers": [{"name": "test", "resources": {"limits": {"cpu": 1, "memory": "1Gi"}, "requests": {"cpu": "500m"}}}]}}}}`,
+			true,
+		},
+		{
+			`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "test"}, "spec": {"template": {"spec": {"containers": [{"name": "test", "resources": {"limits": {"cpu": "1001m"}}}]}}}}`,
+			`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "test"}, "spec": {"template": {"spec": {"containers": [{"name": "test", "resources": {"limits": {"cpu": "1"}}}]}}}}`,
+			false,
+		},
+		{
+			`{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": {"name": "test"}, "spec": {"resources": {"requests": {"storage": "1024Mi"}}}}`,
+			`{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": {"name": "test"}, "spec": {"resources": {"requests": {"storage": "1Gi"}}}}`,
+			true,
+		},
+		// quantities of custom resources are not known
+		{
+			`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}, "spec": {"resources": {"limits": {"cpu": "1000m"}}}}`,
+			`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}, "spec": {"resources": {"limits": {"cpu": "1"}}}}`,
+			false,
+		},
+		// numeric strings of int-or-string fields are not numbers,
+		// e.g. a string targetPort is a port name
+		{
+			`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test"}, "spec": {"ports": [{"port": 80, "targetPort": "8080"}]}}`,
+			`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test"}, "spec": {"ports": [{"port": 80, "targetPort": 8080}]}}`,
+			false,
+		},
+		{
+			`{"apiVersion": "policy/v1", "kind": "PodDisruptionBudget", "metadata": {"name": "test"}, "spec": {"minAvailable": "1"}}`,
+			`{"apiVersion": "policy/v1", "kind": "PodDisruptionBudget", "metadata": {"name": "test"}, "spec": {"minAvailable": 1}}`,
+			false,
+		},
+		{
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}, "data": {"port": "8080"}}`,
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}, "data": {"port": 8080}}`,
+			false,
+		},
+		// numbers
+		{
+			`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}, "spec": {"replicas": 1.0}}`,
+			`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}, "spec": {"replicas": 1}}`,
+			true,
+		},
+		// empty maps that differ from omitted ones
+		{
+			`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test"}, "spec": {"volumes": [{"name": "tmp", "emptyDir": {}}]}}`,
+			`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test"}, "spec": {"volumes": [{"name": "tmp"}]}}`,
+			false,
+		},
+		{
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {}, "ingress": [{"from": [{"namespaceSelector": {}}]}]}}`,
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {}, "ingress": [{"from": [{"podSelector": {}}]}]}}`,
+			false,
+		},
+		{
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {}, "ingress": [{"from": [{"namespaceSelector": {"matchLabels": {}}, "podSelector": {"matchLabels": {"app": "test"}}}]}]}}`,
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {}, "ingress": [{"from": [{"podSelector": {"matchLabels": {"app": "test"}}}]}]}}`,
+			false,
+		},
+		// empty selectors are the same, however they are written
+		{
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {"matchLabels": {}}, "ingress": [{"from": [{"namespaceSelector": {"matchLabels": {}}}]}]}}`,
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "test"}, "spec": {"podSelector": {}, "ingress": [{"from": [{"namespaceSelector": {}}]}]}}`,
+			true,
+		},
+		// invalid
+		{
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}}`,
+			`{`,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.equal, manifestsEqual(tc.a, tc.b), tc.a)
+		assert.Equal(t, tc.equal, manifestsEqual(tc.b, tc.a), tc.b)
+	}
+}
+
+func TestSuppressManifestDiff(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{})
+
+	old := `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test", "namespace": "test"}, "spec": {"ports": [{"port": 80, "targetPort": 8080}]}}`
+	assert.True(t, suppressManifestDiff("manifest", old, `{"kind": "Service", "apiVersion": "v1", "metadata": {"namespace": "test", "name": "test", "labels": {}}, "spec": {"ports": [{"targetPort": 8080, "port": 80}]}}`, d))
+	assert.False(t, suppressManifestDiff("manifest", old, `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test", "namespace": "test"}, "spec": {"ports": [{"port": 80, "targetPort": 9090}]}}`, d))
+	assert.False(t, suppressManifestDiff("manifest", "", old, d))
+
+	assert.True(t, suppressManifestsDiff("manifests._/Service/test/test", old, `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "test", "namespace": "test"}, "spec": {"ports": [{"port": 80.0, "targetPort": 8080}]}}`, nil))
+	assert.False(t, suppressManifestsDiff("manifests.%", "1", "2", nil))
+}
@@ -0,0 +1,283 @@
+package kustomize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const (
+	policyEnforcementError   = "error"
+	policyEnforcementWarning = "warning"
+)
+
+// policyChecks are the built-in checks, each returns
+// the violations of the pod spec of a workload
+var policyChecks = map[string]func(podSpec map[string]interface{}) []string{
+	"privileged":     checkPrivileged,
+	"latest_tag":     checkLatestTag,
+	"missing_limits": checkMissingLimits,
+	"host_path":      checkHostPath,
+}
+
+func policyCheckNames() (names []string) {
+	for name := range policyChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getPolicySchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"enforcement": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      policyEnforcementError,
+				ValidateFunc: validation.StringInSlice([]string{policyEnforcementError, policyEnforcementWarning}, false),
+			},
+			"checks": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(policyCheckNames(), false),
+				},
+			},
+			"rule": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"expression": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"message": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+type policyRule struct {
+	name       string
+	expression string
+	message    string
+	program    cel.Program
+}
+
+type policy struct {
+	severity diag.Severity
+	checks   []string
+	rules    []policyRule
+}
+
+// getPolicies returns the policy blocks with all rules compiled
+func getPolicies(in []interface{}) (policies []policy, err error) {
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range in {
+		if i == nil {
+			continue
+		}
+		p := i.(map[string]interface{})
+
+		pol := policy{severity: diag.Error}
+		if p["enforcement"].(string) == policyEnforcementWarning {
+			pol.severity = diag.Warning
+		}
+
+		for _, c := range p["checks"].([]interface{}) {
+			pol.checks = append(pol.checks, c.(string))
+		}
+
+		for _, r := range p["rule"].([]interface{}) {
+			if r == nil {
+				continue
+			}
+			rule := r.(map[string]interface{})
+			name := rule["name"].(string)
+
+			ast, iss := env.Compile(rule["expression"].(string))
+			if iss.Err() != nil {
+				return nil, fmt.Errorf("rule %q: %s", name, iss.Err())
+			}
+			if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+				return nil, fmt.Errorf("rule %q: expression must return a bool, got %s", name, ast.OutputType())
+			}
+
+			prg, err := env.Program(ast)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %s", name, err)
+			}
+
+			pol.rules = append(pol.rules, policyRule{
+				name:       name,
+				expression: rule["expression"].(string),
+				message:    rule["message"].(string),
+				program:    prg,
+			})
+		}
+
+		policies = append(policies, pol)
+	}
+
+	return policies, nil
+}
+
+// checkPolicies returns a diagnostic for every violation of policies
+// by an object in rm, errors or warnings depending on the enforcement
+func checkPolicies(rm resmap.ResMap, policies []policy) (diags diag.Diagnostics) {
+	for _, r := range rm.Resources() {
+		gvk := r.CurId().Gvk
+		kr := &kManifestId{
+			group:     gvk.Group,
+			kind:      gvk.Kind,
+			namespace: r.GetNamespace(),
+			name:      r.GetName(),
+		}
+
+		js, err := r.MarshalJSON()
+		if err != nil {
+			return append(diags, diag.Errorf("%q: %s", kr.string(), err)...)
+		}
+		km := &kManifest{}
+		if err := km.load(js); err != nil {
+			return append(diags, diag.Errorf("%q: %s", kr.string(), err)...)
+		}
+		obj := km.resource.Object
+
+		var podSpec map[string]interface{}
+		if path, ok := podSpecPaths[fmt.Sprintf("%s/%s", emptyToUnderscore(gvk.Group), gvk.Kind)]; ok {
+			podSpec, _, _ = k8sunstructured.NestedMap(obj, path...)
+		}
+
+		for _, p := range policies {
+			violation := func(name string, detail string) {
+				diags = append(diags, diag.Diagnostic{
+					Severity: p.severity,
+					Summary:  fmt.Sprintf("%q: violates policy %q", kr.string(), name),
+					Detail:   detail,
+				})
+			}
+
+			if podSpec != nil {
+				for _, name := range p.checks {
+					for _, v := range policyChecks[name](podSpec) {
+						violation(name, v)
+					}
+				}
+			}
+
+			for _, rule := range p.rules {
+				out, _, err := rule.program.Eval(map[string]interface{}{"object": obj})
+				if err != nil {
+					violation(rule.name, fmt.Sprintf("evaluation failed: %s", err))
+					continue
+				}
+
+				ok, isBool := out.Value().(bool)
+				if !isBool {
+					violation(rule.name, fmt.Sprintf("evaluation failed: expression returned %s, not a bool", out.Type().TypeName()))
+					continue
+				}
+
+				if !ok {
+					msg := rule.message
+					if msg == "" {
+						msg = fmt.Sprintf("failed expression: %s", rule.expression)
+					}
+					violation(rule.name, msg)
+				}
+			}
+		}
+	}
+
+	return diags
+}
+
+// podContainers returns the init, regular and ephemeral containers
+func podContainers(podSpec map[string]interface{}) (containers []map[string]interface{}) {
+	for _, f := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers = append(containers, nestedMaps(podSpec, f)...)
+	}
+	return containers
+}
+
+func checkPrivileged(podSpec map[string]interface{}) (vs []string) {
+	for _, c := range podContainers(podSpec) {
+		privileged, _, _ := k8sunstructured.NestedBool(c, "securityContext", "privileged")
+		if privileged {
+			vs = append(vs, fmt.Sprintf("container %q is privileged", c["name"]))
+		}
+	}
+	return vs
+}
+
+func checkLatestTag(podSpec map[string]interface{}) (vs []string) {
+	for _, c := range podContainers(podSpec) {
+		image, _, _ := k8sunstructured.NestedString(c, "image")
+		if image == "" || strings.Contains(image, "@") {
+			// images pinned by digest are immutable
+			continue
+		}
+
+		tag := ""
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			tag = image[i+1:]
+		}
+
+		if tag == "" || tag == "latest" {
+			vs = append(vs, fmt.Sprintf("container %q uses image %q without a tag other than latest", c["name"], image))
+		}
+	}
+	return vs
+}
+
+func checkMissingLimits(podSpec map[string]interface{}) (vs []string) {
+	for _, c := range podContainers(podSpec) {
+		limits, _, _ := k8sunstructured.NestedMap(c, "resources", "limits")
+
+		missing := []string{}
+		for _, r := range []string{"cpu", "memory"} {
+			if _, ok := limits[r]; !ok {
+				missing = append(missing, r)
+			}
+		}
+
+		if len(missing) > 0 {
+			vs = append(vs, fmt.Sprintf("container %q has no %s limit", c["name"], strings.Join(missing, " and ")))
+		}
+	}
+	return vs
+}
+
+func checkHostPath(podSpec map[string]interface{}) (vs []string) {
+	for _, v := range nestedMaps(podSpec, "volumes") {
+		path, found, _ := k8sunstructured.NestedString(v, "hostPath", "path")
+		if found {
+			vs = append(vs, fmt.Sprintf("volume %q mounts hostPath %q", v["name"], path))
+		}
+	}
+	return vs
+}
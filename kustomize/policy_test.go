@@ -0,0 +1,142 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCheckPolicies(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("kustomization.yaml", []byte(`
+resources:
+- resources.yaml
+`))
+	fSys.WriteFile("resources.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: compliant
+  namespace: test
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com:5000/app:v1.0.0
+        resources:
+          limits:
+            cpu: 100m
+            memory: 128Mi
+      - name: pinned
+        image: app@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+        resources:
+          limits:
+            cpu: 1
+            memory: 128Mi
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: violating
+  namespace: test
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+          - name: init
+            image: registry.example.com:5000/init
+            securityContext:
+              privileged: true
+          containers:
+          - name: app
+            image: app:latest
+            resources:
+              limits:
+                memory: 128Mi
+          volumes:
+          - name: host
+            hostPath:
+              path: /var/run
+`))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, ".")
+	assert.Equal(t, nil, err)
+
+	policies, err := getPolicies([]interface{}{
+		map[string]interface{}{
+			"enforcement": "error",
+			"checks":      []interface{}{"privileged", "latest_tag", "missing_limits", "host_path"},
+			"rule":        []interface{}{},
+		},
+		map[string]interface{}{
+			"enforcement": "warning",
+			"checks":      []interface{}{},
+			"rule": []interface{}{
+				map[string]interface{}{
+					"name":       "replicas",
+					"expression": "object.kind != 'Deployment' || object.spec.replicas >= 3",
+					"message":    "Deployments must have at least 3 replicas",
+				},
+				map[string]interface{}{
+					"name":       "team-label",
+					"expression": "has(object.metadata.labels) && 'team' in object.metadata.labels",
+					"message":    "",
+				},
+			},
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	diags := checkPolicies(rm, policies)
+
+	expected := diag.Diagnostics{
+		{Severity: diag.Warning, Summary: `"_/ConfigMap/test/test": violates policy "team-label"`, Detail: "failed expression: has(object.metadata.labels) && 'team' in object.metadata.labels"},
+		{Severity: diag.Warning, Summary: `"apps/Deployment/test/compliant": violates policy "replicas"`, Detail: "Deployments must have at least 3 replicas"},
+		{Severity: diag.Warning, Summary: `"apps/Deployment/test/compliant": violates policy "team-label"`, Detail: "failed expression: has(object.metadata.labels) && 'team' in object.metadata.labels"},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "privileged"`, Detail: `container "init" is privileged`},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "latest_tag"`, Detail: `container "init" uses image "registry.example.com:5000/init" without a tag other than latest`},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "latest_tag"`, Detail: `container "app" uses image "app:latest" without a tag other than latest`},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "missing_limits"`, Detail: `container "init" has no cpu and memory limit`},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "missing_limits"`, Detail: `container "app" has no cpu limit`},
+		{Severity: diag.Error, Summary: `"batch/CronJob/test/violating": violates policy "host_path"`, Detail: `volume "host" mounts hostPath "/var/run"`},
+		{Severity: diag.Warning, Summary: `"batch/CronJob/test/violating": violates policy "team-label"`, Detail: "failed expression: has(object.metadata.labels) && 'team' in object.metadata.labels"},
+	}
+	assert.Equal(t, expected, diags)
+}
+
+func TestGetPoliciesInvalidRule(t *testing.T) {
+	rule := func(expression string) []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"enforcement": "error",
+				"checks":      []interface{}{},
+				"rule": []interface{}{
+					map[string]interface{}{
+						"name":       "invalid",
+						"expression": expression,
+						"message":    "",
+					},
+				},
+			},
+		}
+	}
+
+	_, err := getPolicies(rule("object.kind =="))
+	assert.ErrorContains(t, err, `rule "invalid": ERROR`)
+
+	_, err = getPolicies(rule("'not a bool'"))
+	assert.EqualError(t, err, `rule "invalid": expression must return a bool, got string`)
+}
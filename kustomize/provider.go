@@ -1,19 +1,27 @@
 package kustomize
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -24,13 +32,38 @@ type Config struct {
 	Mapper                *restmapper.DeferredDiscoveryRESTMapper
 	Mutex                 *sync.Mutex
 	GzipLastAppliedConfig bool
+	Namespace             string
+	RestConfig            *rest.Config
+	Clusters              *clusterCache
+	Deferred              bool
+	Discovery             *discoveryCache
+	ReadCache             *readCache
+	Retry                 *retryPolicy
+	TargetKubeVersion     string
+	SchemaValidation      bool
+	OpenAPI               *openAPISchemas
+	CRDSchemas            *crdSchemas
+}
+
+// newKManifest returns a kManifest using the client and mapper of c
+// that defaults namespaced objects without a namespace to c.Namespace.
+// API calls and waits of the kManifest are canceled with ctx.
+func (c *Config) newKManifest(ctx context.Context) *kManifest {
+	km := newKManifest(c.Mapper, c.Client)
+	km.ctx = ctx
+	km.defaultNamespace = c.Namespace
+	km.discovery = c.Discovery
+	km.readCache = c.ReadCache
+	km.retry = c.Retry
+	return km
 }
 
 // Provider ...
 func Provider() *schema.Provider {
 	p := &schema.Provider{
 		ResourcesMap: map[string]*schema.Resource{
-			"kustomization_resource": kustomizationResource(),
+			"kustomization_resource":  kustomizationResource(),
+			"kustomization_resources": kustomizationResources(),
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
@@ -41,27 +74,111 @@ func Provider() *schema.Provider {
 
 			// define overlay from TF
 			"kustomization_overlay": dataSourceKustomizationOverlay(),
+
+			// validate manifests against bundled schemas
+			"kustomization_validate": dataSourceKustomizationValidate(),
+
+			// server-side dry-run manifests against the cluster
+			"kustomization_diff": dataSourceKustomizationDiff(),
 		},
 
 		Schema: map[string]*schema.Schema{
 			"kubeconfig_path": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("KUBECONFIG_PATH", nil),
+				ConflictsWith: []string{"kubeconfig_paths", "kubeconfig_raw", "kubeconfig_incluster", "host"},
+				Description:   "Path to a kubeconfig file. Can be set using KUBECONFIG_PATH env var",
+			},
+			"kubeconfig_paths": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"kubeconfig_path", "kubeconfig_raw", "kubeconfig_incluster", "host"},
+				Description:   "List of paths to kubeconfig files, merged the same way as multiple files in the KUBECONFIG env var.",
+			},
+			"kubeconfig_raw": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"kubeconfig_path", "kubeconfig_paths", "kubeconfig_incluster", "host"},
+				Description:   "Raw kube config. If kubeconfig_raw is set, KUBECONFIG_PATH is ignored.",
+			},
+			"kubeconfig_incluster": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				ConflictsWith: []string{"kubeconfig_path", "kubeconfig_paths", "kubeconfig_raw", "host"},
+				Description:   "Set to true when running inside a kubernetes cluster. If kubeconfig_incluster is set, KUBECONFIG_PATH is ignored.",
+			},
+			"host": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"kubeconfig_path", "kubeconfig_paths", "kubeconfig_raw", "kubeconfig_incluster"},
+				Description:   "The hostname (in form of URI) of the Kubernetes API. Configures the provider using the inline credentials instead of a kubeconfig.",
+			},
+			"token": {
 				Type:         schema.TypeString,
 				Optional:     true,
-				DefaultFunc:  schema.EnvDefaultFunc("KUBECONFIG_PATH", nil),
-				ExactlyOneOf: []string{"kubeconfig_path", "kubeconfig_raw", "kubeconfig_incluster"},
-				Description:  "Path to a kubeconfig file. Can be set using KUBECONFIG_PATH env var",
+				Sensitive:    true,
+				RequiredWith: []string{"host"},
+				Description:  "Token to authenticate a service account.",
 			},
-			"kubeconfig_raw": {
+			"cluster_ca_certificate": {
 				Type:         schema.TypeString,
 				Optional:     true,
-				ExactlyOneOf: []string{"kubeconfig_path", "kubeconfig_raw", "kubeconfig_incluster"},
-				Description:  "Raw kube config. If kubeconfig_raw is set, KUBECONFIG_PATH is ignored.",
+				RequiredWith: []string{"host"},
+				Description:  "PEM-encoded root certificates bundle for TLS authentication.",
 			},
-			"kubeconfig_incluster": {
+			"client_certificate": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"host", "client_key"},
+				Description:  "PEM-encoded client certificate for TLS authentication.",
+			},
+			"client_key": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				RequiredWith: []string{"host", "client_certificate"},
+				Description:  "PEM-encoded client certificate key for TLS authentication.",
+			},
+			"insecure": {
 				Type:         schema.TypeBool,
 				Optional:     true,
-				ExactlyOneOf: []string{"kubeconfig_path", "kubeconfig_raw", "kubeconfig_incluster"},
-				Description:  "Set to true when running inside a kubernetes cluster. If kubeconfig_incluster is set, KUBECONFIG_PATH is ignored.",
+				RequiredWith: []string{"host"},
+				Description:  "Whether server should be accessed without verifying the TLS certificate.",
+			},
+			"exec": {
+				Type:         schema.TypeList,
+				Optional:     true,
+				MaxItems:     1,
+				RequiredWith: []string{"host"},
+				Description:  "Exec credential plugin to retrieve credentials, e.g. for EKS, GKE or AKS.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"api_version": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "API version of the ExecCredential, e.g. client.authentication.k8s.io/v1beta1.",
+						},
+						"command": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Command to execute.",
+						},
+						"args": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Arguments to pass when executing the command.",
+						},
+						"env": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Environment variables to set when executing the command.",
+						},
+					},
+				},
 			},
 			"context": {
 				Type:        schema.TypeString,
@@ -69,6 +186,147 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("KUBECONFIG_CONTEXT", nil),
 				Description: "Context to use in kubeconfig with multiple contexts, if not specified the default context is to be used.",
 			},
+			"cluster": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Cluster to use from kubeconfig, overrides the cluster of the context.",
+			},
+			"user": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "User to use from kubeconfig, overrides the user of the context.",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Namespace to use for namespaced resources without a namespace, overrides the namespace of the context.",
+			},
+			"proxy_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateProxyURL,
+				Description:  "URL of the proxy to use for requests to the Kubernetes API, e.g. socks5://localhost:1080. Overrides the proxy-url of the kubeconfig cluster.",
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Server name to use for TLS certificate validation, if it differs from the hostname of the Kubernetes API.",
+			},
+			"impersonate": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Impersonate a user, e.g. a restricted ServiceAccount, for all requests including the plan time dry-runs.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"user": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Username to impersonate, e.g. system:serviceaccount:example:deployer.",
+						},
+						"uid": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "UID to impersonate.",
+						},
+						"groups": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Groups to impersonate.",
+						},
+						"extra": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "Extra fields to impersonate.",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"key": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"values": {
+										Type:     schema.TypeList,
+										Required: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"qps": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      120,
+				ValidateFunc: validation.FloatAtLeast(1),
+				Description:  "Maximum queries per second to the Kubernetes API, before requests are throttled client-side.",
+			},
+			"burst": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      240,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum burst of queries to the Kubernetes API, before requests are throttled client-side.",
+			},
+			"request_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+				Description:  "Timeout for a single request to the Kubernetes API, e.g. 30s. Zero or unset means no timeout.",
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Maximum number of concurrent requests to the Kubernetes API. Zero means no limit.",
+			},
+			"discovery_cache_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Directory to cache API discovery in, shared between provider runs, e.g. ~/.kube/cache/terraform-discovery. If not set, discovery is only cached in memory.",
+			},
+			"discovery_cache_ttl": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10m",
+				ValidateFunc: validateDuration,
+				Description:  "How long API discovery cached in discovery_cache_dir is used, before it is discovered again.",
+			},
+			"retry_max_attempts": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum number of attempts for API calls failing with transient errors, like throttling, server errors or conflicts. Set to 1 to disable retries.",
+			},
+			"retry_max_elapsed": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "2m",
+				ValidateFunc: validateDuration,
+				Description:  "Maximum time to retry an API call for, e.g. 2m. Zero means no limit.",
+			},
+			"read_cache": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "When 'true' refresh resources from one list request per resource type and namespace, instead of one get request per resource. Writes always go to the API server directly.",
+			},
+			"schema_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "When 'true' validate manifests during plan against the cluster's OpenAPI v3 schema, and the schemas of CRDs of the same run, reporting all unknown fields and type errors.",
+			},
+			"target_kube_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateKubeVersion,
+				Description:  "Default Kubernetes version, e.g. 1.25, the build and overlay data sources check the apiVersions of all objects against. Objects using removed apiVersions are errors, deprecated apiVersions warnings.",
+			},
 			"gzip_last_applied_config": {
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -78,17 +336,31 @@ func Provider() *schema.Provider {
 		},
 	}
 
-	p.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+	providerConfigure := func(d *schema.ResourceData) (interface{}, error) {
 		var config *rest.Config
 		var err error
 
 		raw := d.Get("kubeconfig_raw").(string)
 		path := d.Get("kubeconfig_path").(string)
+		paths := convertListInterfaceToListString(d.Get("kubeconfig_paths").([]interface{}))
 		incluster := d.Get("kubeconfig_incluster").(bool)
-		context := d.Get("context").(string)
+		overrides := getConfigOverrides(d)
+		namespace := overrides.Context.Namespace
+
+		// the provider configuration depends on values that are only
+		// known during apply, e.g. of a cluster created in the same run
+		deferred := hasUnknownValues(d.GetRawConfig())
+		if deferred {
+			raw, path, paths, incluster = "", "", nil, false
+		}
 
 		if raw != "" {
-			config, err = getClientConfig([]byte(raw), context)
+			kubeconfig, err := clientcmd.Load([]byte(raw))
+			if err != nil {
+				return nil, fmt.Errorf("provider kustomization: kubeconfig_raw: %s", err)
+			}
+
+			config, namespace, err = getClientConfig(kubeconfig, overrides)
 			if err != nil {
 				return nil, fmt.Errorf("provider kustomization: kubeconfig_raw: %s", err)
 			}
@@ -100,12 +372,29 @@ func Provider() *schema.Provider {
 				return nil, fmt.Errorf("provider kustomization: kubeconfig_path: %s", err)
 			}
 
-			config, err = getClientConfig(data, context)
+			kubeconfig, err := clientcmd.Load(data)
+			if err != nil {
+				return nil, fmt.Errorf("provider kustomization: kubeconfig_path: %s", err)
+			}
+
+			config, namespace, err = getClientConfig(kubeconfig, overrides)
 			if err != nil {
 				return nil, fmt.Errorf("provider kustomization: kubeconfig_path: %s", err)
 			}
 		}
 
+		if len(paths) > 0 {
+			kubeconfig, err := loadKubeconfigFiles(paths)
+			if err != nil {
+				return nil, fmt.Errorf("provider kustomization: kubeconfig_paths: %s", err)
+			}
+
+			config, namespace, err = getClientConfig(kubeconfig, overrides)
+			if err != nil {
+				return nil, fmt.Errorf("provider kustomization: kubeconfig_paths: %s", err)
+			}
+		}
+
 		if incluster {
 			config, err = rest.InClusterConfig()
 			if err != nil {
@@ -113,6 +402,10 @@ func Provider() *schema.Provider {
 			}
 		}
 
+		if !deferred && d.Get("host").(string) != "" {
+			config = getInlineConfig(d)
+		}
+
 		// empty default config required to support
 		// using a cluster resource or data source
 		// that may not exist yet, to configure the provider
@@ -120,9 +413,41 @@ func Provider() *schema.Provider {
 			config = &rest.Config{}
 		}
 
-		// Increase QPS and Burst rate limits
-		config.QPS = 120
-		config.Burst = 240
+		if v := d.Get("proxy_url").(string); v != "" {
+			// already validated by validateProxyURL
+			u, _ := url.Parse(v)
+			config.Proxy = http.ProxyURL(u)
+		}
+
+		if v := d.Get("tls_server_name").(string); v != "" {
+			config.TLSClientConfig.ServerName = v
+		}
+
+		if v, ok := d.GetOk("impersonate"); ok {
+			config.Impersonate = expandImpersonationConfig(v.([]interface{}))
+		}
+
+		config.QPS = float32(d.Get("qps").(float64))
+		config.Burst = d.Get("burst").(int)
+		config.RateLimiter = newLoggingRateLimiter(config.QPS, config.Burst)
+
+		if v := d.Get("request_timeout").(string); v != "" {
+			// already validated by validateDuration
+			config.Timeout, _ = time.ParseDuration(v)
+		}
+
+		config.WarningHandler = warningLogger{}
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &warningTransport{rt}
+		})
+
+		if v := d.Get("max_concurrent_requests").(int); v > 0 {
+			config.Wrap(newConcurrencyLimiter(v))
+		} else {
+			config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+				return &serverThrottlingLogger{rt}
+			})
+		}
 
 		client, err := dynamic.NewForConfig(config)
 		if err != nil {
@@ -134,7 +459,15 @@ func Provider() *schema.Provider {
 			return nil, fmt.Errorf("provider kustomization: %s", err)
 		}
 
-		mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+		discoveryCacheDir, err := homedir.Expand(d.Get("discovery_cache_dir").(string))
+		if err != nil {
+			return nil, fmt.Errorf("provider kustomization: discovery_cache_dir: %s", err)
+		}
+		// already validated by validateDuration
+		discoveryCacheTTL, _ := time.ParseDuration(d.Get("discovery_cache_ttl").(string))
+
+		cache := newDiscoveryCache(dc, getDiscoveryCacheDir(discoveryCacheDir, config.Host), discoveryCacheTTL)
+		mapper := restmapper.NewDeferredDiscoveryRESTMapper(cache)
 
 		// Mutex to prevent parallel Kustomizer runs
 		// temp workaround for upstream bug
@@ -143,12 +476,65 @@ func Provider() *schema.Provider {
 
 		gzipLastAppliedConfig := d.Get("gzip_last_applied_config").(bool)
 
-		return &Config{client, mapper, mu, gzipLastAppliedConfig}, nil
+		// already validated by validateDuration
+		retryMaxElapsed, _ := time.ParseDuration(d.Get("retry_max_elapsed").(string))
+		retry := newRetryPolicy(d.Get("retry_max_attempts").(int), retryMaxElapsed)
+
+		var rc *readCache
+		if d.Get("read_cache").(bool) {
+			rc = newReadCache()
+		}
+
+		return &Config{
+			Client:                client,
+			Mapper:                mapper,
+			Mutex:                 mu,
+			GzipLastAppliedConfig: gzipLastAppliedConfig,
+			Namespace:             namespace,
+			RestConfig:            config,
+			Clusters:              newClusterCache(),
+			Deferred:              deferred,
+			Discovery:             cache,
+			ReadCache:             rc,
+			Retry:                 retry,
+			TargetKubeVersion:     d.Get("target_kube_version").(string),
+			SchemaValidation:      d.Get("schema_validation").(bool),
+			OpenAPI:               newOpenAPISchemas(dc.OpenAPIV3()),
+			CRDSchemas:            newCRDSchemas(),
+		}, nil
+	}
+
+	p.ConfigureContextFunc = func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		meta, err := providerConfigure(d)
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+
+		var diags diag.Diagnostics
+		if meta.(*Config).Deferred {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Provider configuration not known until apply",
+				Detail:   "The kustomization provider configuration depends on values that are only known during apply, e.g. of a cluster created in the same run. Plan time requests to the cluster, like the server-side dry-runs validating manifests, are skipped. Errors will only show during apply.",
+			})
+		}
+
+		return meta, diags
 	}
 
 	return p
 }
 
+// hasUnknownValues returns true if any value in
+// the raw configuration is not known yet
+func hasUnknownValues(raw cty.Value) bool {
+	if raw.IsNull() {
+		return false
+	}
+
+	return !raw.IsWhollyKnown()
+}
+
 func readKubeconfigFile(s string) ([]byte, error) {
 	p, err := homedir.Expand(s)
 	if err != nil {
@@ -163,21 +549,150 @@ func readKubeconfigFile(s string) ([]byte, error) {
 	return data, nil
 }
 
-func getClientConfig(data []byte, context string) (*rest.Config, error) {
-	if len(context) == 0 {
-		return clientcmd.RESTConfigFromKubeConfig(data)
+func getInlineConfig(d *schema.ResourceData) *rest.Config {
+	config := &rest.Config{
+		Host:        d.Get("host").(string),
+		BearerToken: d.Get("token").(string),
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: d.Get("insecure").(bool),
+			CAData:   []byte(d.Get("cluster_ca_certificate").(string)),
+			CertData: []byte(d.Get("client_certificate").(string)),
+			KeyData:  []byte(d.Get("client_key").(string)),
+		},
+	}
+
+	if v, ok := d.GetOk("exec"); ok {
+		config.ExecProvider = expandExecConfig(v.([]interface{}))
+	}
+
+	return config
+}
+
+func expandExecConfig(l []interface{}) *clientcmdapi.ExecConfig {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+
+	in := l[0].(map[string]interface{})
+
+	exec := &clientcmdapi.ExecConfig{
+		APIVersion:      in["api_version"].(string),
+		Command:         in["command"].(string),
+		Args:            convertListInterfaceToListString(in["args"].([]interface{})),
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+
+	env := convertMapStringInterfaceToMapStringString(in["env"].(map[string]interface{}))
+	names := []string{}
+	for k := range env {
+		names = append(names, k)
 	}
+	sort.Strings(names)
 
-	rawConfig, err := clientcmd.Load(data)
+	for _, k := range names {
+		exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: k, Value: env[k]})
+	}
+
+	return exec
+}
+
+func validateProxyURL(v interface{}, k string) (ws []string, es []error) {
+	u, err := url.Parse(v.(string))
 	if err != nil {
-		return nil, err
+		es = append(es, fmt.Errorf("%s: %s", k, err))
+		return ws, es
 	}
 
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		es = append(es, fmt.Errorf("%s: unsupported proxy scheme %q, must be one of http, https or socks5", k, u.Scheme))
+	}
+
+	if u.Host == "" {
+		es = append(es, fmt.Errorf("%s: %q has no host", k, v))
+	}
+
+	return ws, es
+}
+
+func expandImpersonationConfig(l []interface{}) rest.ImpersonationConfig {
+	if len(l) == 0 || l[0] == nil {
+		return rest.ImpersonationConfig{}
+	}
+
+	in := l[0].(map[string]interface{})
+
+	ic := rest.ImpersonationConfig{
+		UserName: in["user"].(string),
+		UID:      in["uid"].(string),
+		Groups:   convertListInterfaceToListString(in["groups"].([]interface{})),
+	}
+
+	for _, e := range in["extra"].([]interface{}) {
+		extra := e.(map[string]interface{})
+		if ic.Extra == nil {
+			ic.Extra = make(map[string][]string)
+		}
+		key := extra["key"].(string)
+		ic.Extra[key] = append(ic.Extra[key], convertListInterfaceToListString(extra["values"].([]interface{}))...)
+	}
+
+	return ic
+}
+
+// loadKubeconfigFiles merges the kubeconfig files using the same
+// rules as kubectl for multiple files in the KUBECONFIG env var
+func loadKubeconfigFiles(paths []string) (*clientcmdapi.Config, error) {
+	rules := &clientcmd.ClientConfigLoadingRules{}
+	for _, s := range paths {
+		p, err := homedir.Expand(s)
+		if err != nil {
+			return nil, err
+		}
+		rules.Precedence = append(rules.Precedence, p)
+	}
+
+	return rules.Load()
+}
+
+func getConfigOverrides(d *schema.ResourceData) *clientcmd.ConfigOverrides {
+	return &clientcmd.ConfigOverrides{
+		CurrentContext: d.Get("context").(string),
+		Context: clientcmdapi.Context{
+			Cluster:   d.Get("cluster").(string),
+			AuthInfo:  d.Get("user").(string),
+			Namespace: d.Get("namespace").(string),
+		},
+	}
+}
+
+// getClientConfig returns the rest config and the namespace of the
+// context selected by overrides, or the current context by default
+func getClientConfig(kubeconfig *clientcmdapi.Config, overrides *clientcmd.ConfigOverrides) (*rest.Config, string, error) {
 	var clientConfig clientcmd.ClientConfig = clientcmd.NewNonInteractiveClientConfig(
-		*rawConfig,
-		context,
-		&clientcmd.ConfigOverrides{CurrentContext: context},
+		*kubeconfig,
+		overrides.CurrentContext,
+		overrides,
 		nil)
 
-	return clientConfig.ClientConfig()
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// unlike kubectl, don't fall back to the default namespace,
+	// only use a namespace that is set explicitly
+	namespace := overrides.Context.Namespace
+	if namespace == "" {
+		name := overrides.CurrentContext
+		if name == "" {
+			name = kubeconfig.CurrentContext
+		}
+		if c, ok := kubeconfig.Contexts[name]; ok {
+			namespace = c.Namespace
+		}
+	}
+
+	return config, namespace, nil
 }
@@ -1,9 +1,28 @@
 package kustomize
 
 import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 var testAccProviders map[string]*schema.Provider
@@ -26,3 +45,327 @@ func TestProvider(t *testing.T) {
 func TestProvider_impl(t *testing.T) {
 	var _ schema.Provider = *Provider()
 }
+
+func TestProviderInlineConfig(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"host":                   "https://127.0.0.1:6443",
+		"token":                  "test-token",
+		"cluster_ca_certificate": "test-ca",
+		"insecure":               true,
+		"exec": []interface{}{
+			map[string]interface{}{
+				"api_version": "client.authentication.k8s.io/v1beta1",
+				"command":     "aws",
+				"args":        []interface{}{"eks", "get-token", "--cluster-name", "test"},
+				"env": map[string]interface{}{
+					"AWS_PROFILE": "test",
+					"AWS_REGION":  "eu-west-1",
+				},
+			},
+		},
+	})
+
+	config := getInlineConfig(d)
+	assert.Equal(t, "https://127.0.0.1:6443", config.Host)
+	assert.Equal(t, "test-token", config.BearerToken)
+	assert.Equal(t, []byte("test-ca"), config.TLSClientConfig.CAData)
+	assert.Equal(t, true, config.TLSClientConfig.Insecure)
+
+	assert.Equal(t, "aws", config.ExecProvider.Command)
+	assert.Equal(t, []string{"eks", "get-token", "--cluster-name", "test"}, config.ExecProvider.Args)
+	assert.Equal(t, []clientcmdapi.ExecEnvVar{
+		{Name: "AWS_PROFILE", Value: "test"},
+		{Name: "AWS_REGION", Value: "eu-west-1"},
+	}, config.ExecProvider.Env)
+}
+
+func TestProviderInlineConfigConflicts(t *testing.T) {
+	raw := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":           "https://127.0.0.1:6443",
+		"kubeconfig_raw": "test",
+	})
+
+	diags := Provider().Validate(raw)
+	assert.True(t, diags.HasError())
+}
+
+const testKubeconfigA = `apiVersion: v1
+kind: Config
+current-context: a
+clusters:
+- name: a
+  cluster:
+    server: https://a.example.com
+contexts:
+- name: a
+  context:
+    cluster: a
+    user: a
+users:
+- name: a
+  user:
+    token: token-a
+`
+
+const testKubeconfigB = `apiVersion: v1
+kind: Config
+current-context: b
+clusters:
+- name: b
+  cluster:
+    server: https://b.example.com
+contexts:
+- name: b
+  context:
+    cluster: b
+    user: b
+    namespace: test
+users:
+- name: b
+  user:
+    token: token-b
+`
+
+func TestProviderKubeconfigPaths(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	assert.Equal(t, nil, os.WriteFile(a, []byte(testKubeconfigA), 0600))
+	assert.Equal(t, nil, os.WriteFile(b, []byte(testKubeconfigB), 0600))
+
+	kubeconfig, err := loadKubeconfigFiles([]string{a, b})
+	assert.Equal(t, nil, err)
+
+	// current-context of the first file wins
+	config, namespace, err := getClientConfig(kubeconfig, &clientcmd.ConfigOverrides{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "https://a.example.com", config.Host)
+	assert.Equal(t, "token-a", config.BearerToken)
+	assert.Equal(t, "", namespace)
+
+	config, namespace, err = getClientConfig(kubeconfig, &clientcmd.ConfigOverrides{CurrentContext: "b"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "https://b.example.com", config.Host)
+	assert.Equal(t, "test", namespace)
+}
+
+func TestProviderKubeconfigOverrides(t *testing.T) {
+	kubeconfig, err := clientcmd.Load([]byte(testKubeconfigA))
+	assert.Equal(t, nil, err)
+	b, err := clientcmd.Load([]byte(testKubeconfigB))
+	assert.Equal(t, nil, err)
+	kubeconfig.Clusters["b"] = b.Clusters["b"]
+	kubeconfig.AuthInfos["b"] = b.AuthInfos["b"]
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"cluster":   "b",
+		"user":      "b",
+		"namespace": "override",
+	})
+
+	config, namespace, err := getClientConfig(kubeconfig, getConfigOverrides(d))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "https://b.example.com", config.Host)
+	assert.Equal(t, "token-b", config.BearerToken)
+	assert.Equal(t, "override", namespace)
+}
+
+func TestProviderImpersonationConfig(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"impersonate": []interface{}{
+			map[string]interface{}{
+				"user":   "system:serviceaccount:test:deployer",
+				"uid":    "1234",
+				"groups": []interface{}{"system:serviceaccounts"},
+				"extra": []interface{}{
+					map[string]interface{}{
+						"key":    "scopes",
+						"values": []interface{}{"a", "b"},
+					},
+				},
+			},
+		},
+	})
+
+	ic := expandImpersonationConfig(d.Get("impersonate").([]interface{}))
+	assert.Equal(t, rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:test:deployer",
+		UID:      "1234",
+		Groups:   []string{"system:serviceaccounts"},
+		Extra:    map[string][]string{"scopes": {"a", "b"}},
+	}, ic)
+}
+
+func testConfigureProvider(t *testing.T, raw map[string]interface{}) *Config {
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(raw))
+	assert.False(t, diags.HasError(), "%v", diags)
+
+	return p.Meta().(*Config)
+}
+
+// testProxy is a stand-in for an HTTP proxy in front of the API server,
+// that answers all requests itself and records the requested hosts
+func testProxy(t *testing.T) (*httptest.Server, *[]string) {
+	mu := sync.Mutex{}
+	hosts := []string{}
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	return proxy, &hosts
+}
+
+var testNamespaceGVR = k8sschema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+func TestProviderProxyURL(t *testing.T) {
+	proxy, hosts := testProxy(t)
+
+	config := testConfigureProvider(t, map[string]interface{}{
+		"host":      "http://kubernetes.invalid",
+		"proxy_url": proxy.URL,
+	})
+
+	ns, err := config.Client.Resource(testNamespaceGVR).Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "test", ns.GetName())
+	assert.Equal(t, []string{"kubernetes.invalid"}, *hosts)
+}
+
+func TestProviderKubeconfigProxyURL(t *testing.T) {
+	proxy, hosts := testProxy(t)
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: http://kubernetes.invalid
+    proxy-url: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: test
+`, proxy.URL)
+
+	config := testConfigureProvider(t, map[string]interface{}{
+		"kubeconfig_raw": kubeconfig,
+	})
+
+	_, err := config.Client.Resource(testNamespaceGVR).Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"kubernetes.invalid"}, *hosts)
+}
+
+func TestProviderTLSServerName(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`))
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	// the test certificate is not valid for localhost
+	host := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	config := testConfigureProvider(t, map[string]interface{}{
+		"host":                   host,
+		"cluster_ca_certificate": string(ca),
+	})
+	_, err := config.Client.Resource(testNamespaceGVR).Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.NotEqual(t, nil, err)
+
+	config = testConfigureProvider(t, map[string]interface{}{
+		"host":                   host,
+		"cluster_ca_certificate": string(ca),
+		"tls_server_name":        "example.com",
+	})
+	_, err = config.Client.Resource(testNamespaceGVR).Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.Equal(t, nil, err)
+}
+
+func TestValidateProxyURL(t *testing.T) {
+	for _, valid := range []string{"http://proxy:3128", "https://proxy", "socks5://localhost:1080"} {
+		_, es := validateProxyURL(valid, "proxy_url")
+		assert.Equal(t, 0, len(es), valid)
+	}
+
+	for _, invalid := range []string{"proxy:3128", "ftp://proxy", "socks5://"} {
+		_, es := validateProxyURL(invalid, "proxy_url")
+		assert.NotEqual(t, 0, len(es), invalid)
+	}
+}
+
+func TestProviderDeferredConfig(t *testing.T) {
+	c := terraform.NewResourceConfigRaw(map[string]interface{}{})
+	c.CtyValue = cty.ObjectVal(map[string]cty.Value{
+		"host":  cty.UnknownVal(cty.String),
+		"token": cty.StringVal("test"),
+	})
+
+	p := Provider()
+	diags := p.Configure(context.Background(), c)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, diag.Warning, diags[0].Severity)
+	assert.True(t, p.Meta().(*Config).Deferred)
+
+	// resources without a cluster of their own skip requests
+	assert.True(t, isDeferred([]interface{}{}, p.Meta()))
+	assert.False(t, isDeferred(testCluster("https://a.example.com", ""), p.Meta()))
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`,
+	})
+	d.SetId("test")
+	assert.False(t, kustomizationResourceRead(context.Background(), d, p.Meta()).HasError())
+	assert.Equal(t, "test", d.Id())
+}
+
+func TestResourceDiffDeferredConfig(t *testing.T) {
+	m := &Config{Deferred: true}
+	r := kustomizationResource()
+
+	state := &terraform.InstanceState{
+		ID: "test",
+		Attributes: map[string]string{
+			"id":       "test",
+			"manifest": `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test"},"data":{"key":"value"}}`,
+		},
+	}
+
+	// renames are re-created, even if the cluster is not known yet
+	diff, err := r.SimpleDiff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"manifest": `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"renamed","namespace":"test"},"data":{"key":"value"}}`,
+	}), m)
+	assert.Equal(t, nil, err)
+	assert.True(t, diff.RequiresNew())
+
+	// other changes are updates, the stored manifest is known after apply
+	diff, err = r.SimpleDiff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"manifest": `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test"},"data":{"key":"changed"}}`,
+	}), m)
+	assert.Equal(t, nil, err)
+	assert.False(t, diff.RequiresNew())
+	assert.True(t, diff.Attributes["manifest_yaml"].NewComputed)
+}
+
+func TestProviderKnownConfig(t *testing.T) {
+	config := testConfigureProvider(t, map[string]interface{}{
+		"host": "https://127.0.0.1:6443",
+	})
+	assert.False(t, config.Deferred)
+}
@@ -0,0 +1,119 @@
+package kustomize
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdynamic "k8s.io/client-go/dynamic"
+)
+
+// page size used to list objects into the read cache
+const readCacheListLimit = 500
+
+// readCache serves reads of many objects of the same resource and
+// namespace from a single list request, instead of one get per object.
+// Objects written through the provider are read directly afterwards.
+type readCache struct {
+	mu    sync.Mutex
+	lists map[string]*readCacheList
+}
+
+type readCacheList struct {
+	once    sync.Once
+	mu      sync.Mutex
+	objects map[string]*k8sunstructured.Unstructured
+	// names written since the list, read directly instead
+	written map[string]bool
+	// list failed, e.g. missing RBAC list permission, read directly
+	err error
+}
+
+func newReadCache() *readCache {
+	return &readCache{lists: make(map[string]*readCacheList)}
+}
+
+func readCacheKey(gvr k8sschema.GroupVersionResource, namespace string) string {
+	return gvr.String() + "|" + namespace
+}
+
+func (rc *readCache) list(key string) *readCacheList {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	l, ok := rc.lists[key]
+	if !ok {
+		l = &readCacheList{
+			objects: make(map[string]*k8sunstructured.Unstructured),
+			written: make(map[string]bool),
+		}
+		rc.lists[key] = l
+	}
+
+	return l
+}
+
+// get returns the object name from the list of api, listing
+// all objects of api on first use. It falls back to a get request,
+// if listing failed or the object was written since the list.
+func (rc *readCache) get(ctx context.Context, api k8sdynamic.ResourceInterface, gvr k8sschema.GroupVersionResource, namespace string, name string) (*k8sunstructured.Unstructured, error) {
+	l := rc.list(readCacheKey(gvr, namespace))
+
+	l.once.Do(func() {
+		l.err = l.load(ctx, api)
+		if l.err != nil {
+			log.Printf("[DEBUG] read cache: listing %s in namespace %q failed, falling back to get requests: %s", gvr, namespace, l.err)
+		}
+	})
+
+	l.mu.Lock()
+	obj, ok := l.objects[name]
+	direct := l.err != nil || l.written[name]
+	l.mu.Unlock()
+
+	if direct {
+		return api.Get(ctx, name, k8smetav1.GetOptions{})
+	}
+
+	if !ok {
+		return nil, k8serrors.NewNotFound(gvr.GroupResource(), name)
+	}
+
+	return obj.DeepCopy(), nil
+}
+
+func (l *readCacheList) load(ctx context.Context, api k8sdynamic.ResourceInterface) error {
+	opts := k8smetav1.ListOptions{Limit: readCacheListLimit}
+	for {
+		list, err := api.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		l.mu.Lock()
+		for i := range list.Items {
+			l.objects[list.Items[i].GetName()] = &list.Items[i]
+		}
+		l.mu.Unlock()
+
+		if list.GetContinue() == "" {
+			return nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
+
+// written marks name as changed, so it is read directly from now on
+func (rc *readCache) written(gvr k8sschema.GroupVersionResource, namespace string, name string) {
+	l := rc.list(readCacheKey(gvr, namespace))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.objects, name)
+	l.written[name] = true
+}
@@ -0,0 +1,89 @@
+package kustomize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testConfigMapGVR = k8sschema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func testReadCacheClient() *fakedynamic.FakeDynamicClient {
+	objs := []k8sruntime.Object{}
+	for _, name := range []string{"a", "b"} {
+		u := &k8sunstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace("test")
+		u.SetName(name)
+		objs = append(objs, u)
+	}
+
+	return fakedynamic.NewSimpleDynamicClientWithCustomListKinds(
+		k8sruntime.NewScheme(),
+		map[k8sschema.GroupVersionResource]string{testConfigMapGVR: "ConfigMapList"},
+		objs...,
+	)
+}
+
+func countActions(client *fakedynamic.FakeDynamicClient, verb string) (n int) {
+	for _, a := range client.Actions() {
+		if a.GetVerb() == verb {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReadCache(t *testing.T) {
+	client := testReadCacheClient()
+	api := client.Resource(testConfigMapGVR).Namespace("test")
+	rc := newReadCache()
+
+	for _, name := range []string{"a", "b"} {
+		obj, err := rc.get(context.Background(), api, testConfigMapGVR, "test", name)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, name, obj.GetName())
+	}
+
+	_, err := rc.get(context.Background(), api, testConfigMapGVR, "test", "missing")
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	assert.Equal(t, 1, countActions(client, "list"))
+	assert.Equal(t, 0, countActions(client, "get"))
+
+	// objects written through the provider are read directly
+	rc.written(testConfigMapGVR, "test", "a")
+	obj, err := rc.get(context.Background(), api, testConfigMapGVR, "test", "a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "a", obj.GetName())
+	assert.Equal(t, 1, countActions(client, "list"))
+	assert.Equal(t, 1, countActions(client, "get"))
+}
+
+func TestReadCacheListFailed(t *testing.T) {
+	client := testReadCacheClient()
+	client.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(testConfigMapGVR.GroupResource(), "", nil)
+	})
+	api := client.Resource(testConfigMapGVR).Namespace("test")
+	rc := newReadCache()
+
+	obj, err := rc.get(context.Background(), api, testConfigMapGVR, "test", "a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "a", obj.GetName())
+
+	_, err = rc.get(context.Background(), api, testConfigMapGVR, "test", "missing")
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	assert.Equal(t, 1, countActions(client, "list"))
+	assert.Equal(t, 2, countActions(client, "get"))
+}
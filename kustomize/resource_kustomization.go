@@ -4,42 +4,69 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	k8scorev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smeta "k8s.io/apimachinery/pkg/api/meta"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 func kustomizationResource() *schema.Resource {
 	return &schema.Resource{
-		Create:        kustomizationResourceCreate,
-		Read:          kustomizationResourceRead,
+		CreateContext: collectWarnings(kustomizationResourceCreate),
+		ReadContext:   collectWarnings(kustomizationResourceRead),
 		Exists:        kustomizationResourceExists,
-		Update:        kustomizationResourceUpdate,
-		Delete:        kustomizationResourceDelete,
+		UpdateContext: collectWarnings(kustomizationResourceUpdate),
+		DeleteContext: collectWarnings(kustomizationResourceDelete),
 		CustomizeDiff: kustomizationResourceDiff,
 
 		Importer: &schema.ResourceImporter{
-			State: kustomizationResourceImport,
+			StateContext: kustomizationResourceImport,
 		},
 
 		Schema: map[string]*schema.Schema{
 			"manifest": &schema.Schema{
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressManifestDiff,
+			},
+			"manifest_yaml": &schema.Schema{
 				Type:     schema.TypeString,
-				Required: true,
+				Computed: true,
 			},
 			"wait": &schema.Schema{
 				Type:     schema.TypeBool,
 				Default:  false,
 				Optional: true,
 			},
+			"cluster": getClusterSchema(),
+			"triggers": &schema.Schema{
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"hash_sensitive_fields": &schema.Schema{
+				Type:     schema.TypeBool,
+				Default:  false,
+				Optional: true,
+			},
+			"sensitive_fields": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSensitiveField,
+				},
+			},
 		},
 
 		Timeouts: &schema.ResourceTimeout{
@@ -50,26 +77,49 @@ func kustomizationResource() *schema.Resource {
 	}
 }
 
-func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
-	mapper := m.(*Config).Mapper
-	client := m.(*Config).Client
-	km := newKManifest(mapper, client)
+func kustomizationResourceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	km := m.(*Config).newKManifest(ctx)
 
-	err := km.load([]byte(d.Get("manifest").(string)))
+	err = km.load([]byte(d.Get("manifest").(string)))
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
 	}
 
+	resp, err := createManifest(km, m, d.Timeout(schema.TimeoutCreate), d.Get("wait").(bool))
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	id := string(resp.GetUID())
+	d.SetId(id)
+
+	err = setManifest(d, resp, m.(*Config).GzipLastAppliedConfig)
+	if err != nil {
+		return diag.FromErr(logError(km.fmtErr(err)))
+	}
+
+	return kustomizationResourceRead(ctx, d, m)
+}
+
+func createManifest(km *kManifest, m interface{}, t time.Duration, wait bool) (resp *k8sunstructured.Unstructured, err error) {
+	mapper := m.(*Config).Mapper
+	client := m.(*Config).Client
+
 	// required for CRDs
-	err = km.waitKind(d.Timeout(schema.TimeoutCreate))
+	err = km.waitKind(t)
 	if err != nil {
-		return logError(err)
+		return nil, err
 	}
 
 	// required for namespaced resources
-	err = km.waitNamespace(d.Timeout(schema.TimeoutCreate))
+	err = km.waitNamespace(t)
 	if err != nil {
-		return logError(err)
+		return nil, err
 	}
 
 	// for secrets of type service account token
@@ -88,66 +138,191 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 					Kind:    "ServiceAccount"}
 				mapping, err := mapper.RESTMapping(saGvk.GroupKind(), saGvk.GroupVersion().Version)
 				if err != nil {
-					return logError(km.fmtErr(
+					return nil, km.fmtErr(
 						fmt.Errorf("api error: %q: %s", saGvk.String(), err),
-					))
+					)
 				}
 
-				_, err = waitForGVKCreated(d, client, mapping, km.namespace(), v)
+				_, err = waitForGVKCreated(km.context(), t, client, mapping, km.namespace(), v)
 				if err != nil {
-					return logError(km.fmtErr(fmt.Errorf("timed out waiting for: %q: %s", km.id().string(), err)))
+					return nil, km.fmtErr(fmt.Errorf("timed out waiting for: %q: %s", km.id().string(), err))
 				}
 			}
 		}
 	}
 
-	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
-	setLastAppliedConfig(km, gzipLastAppliedConfig)
+	setLastAppliedConfig(km, m.(*Config).GzipLastAppliedConfig)
 
-	resp, err := km.apiCreate(k8smetav1.CreateOptions{})
+	resp, err = km.apiCreate(k8smetav1.CreateOptions{})
 	if err != nil {
-		return logError(err)
+		return nil, err
 	}
 
-	if d.Get("wait").(bool) {
-		if err = km.waitCreatedOrUpdated(d.Timeout(schema.TimeoutCreate)); err != nil {
-			return logError(err)
+	if wait {
+		if err = km.waitCreatedOrUpdated(t); err != nil {
+			return nil, err
 		}
 	}
 
-	id := string(resp.GetUID())
-	d.SetId(id)
+	return resp, nil
+}
 
-	d.Set("manifest", getLastAppliedConfig(resp, gzipLastAppliedConfig))
+func kustomizationResourceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if isDeferred(d.Get("cluster"), m) {
+		// keep the prior state until the provider configuration is known
+		log.Printf("[WARN] %s: skipping refresh, provider configuration not known until apply", d.Id())
+		return nil
+	}
 
-	return kustomizationResourceRead(d, m)
-}
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
 
-func kustomizationResourceRead(d *schema.ResourceData, m interface{}) error {
-	km := newKManifest(m.(*Config).Mapper, m.(*Config).Client)
+	km := m.(*Config).newKManifest(ctx)
 
-	err := km.load([]byte(d.Get("manifest").(string)))
+	err = km.load([]byte(d.Get("manifest").(string)))
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
 	}
 
-	resp, err := km.apiGet(k8smetav1.GetOptions{})
+	migratedFrom, err := km.migrateAPIVersion()
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
+	}
+
+	resp, err := km.apiGetCached()
+	if err != nil {
+		return diag.FromErr(logError(err))
 	}
 
 	id := string(resp.GetUID())
 	d.SetId(id)
 
-	d.Set("manifest", getLastAppliedConfig(resp, m.(*Config).GzipLastAppliedConfig))
+	if migratedFrom != "" {
+		// store the served apiVersion, so the plan
+		// shows an apiVersion upgrade instead of errors
+		err = migrateLastAppliedConfig(resp, km.resource.GetAPIVersion(), m.(*Config).GzipLastAppliedConfig)
+		if err != nil {
+			return diag.FromErr(logError(km.fmtErr(err)))
+		}
+	}
+
+	err = setManifest(d, resp, m.(*Config).GzipLastAppliedConfig)
+	if err != nil {
+		return diag.FromErr(logError(km.fmtErr(err)))
+	}
+
+	return nil
+}
+
+// setManifest stores the lastAppliedConfig of resp as the manifest,
+// with the values of sensitive fields replaced by their hashes
+// if hash_sensitive_fields is true. The manifest_yaml shown in
+// plans always uses the hashes.
+func setManifest(d *schema.ResourceData, resp *k8sunstructured.Unstructured, gzipLastAppliedConfig bool) error {
+	manifest := getLastAppliedConfig(resp, gzipLastAppliedConfig)
+
+	hashed := manifest
+	if manifest != "" {
+		sfs, err := getSensitiveFields(d.Get("sensitive_fields").([]interface{}))
+		if err != nil {
+			return err
+		}
+
+		hashed, err = hashSensitiveFields(manifest, resp, sfs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.Get("hash_sensitive_fields").(bool) {
+		manifest = hashed
+	}
+
+	d.Set("manifest", manifest)
+
+	manifestYAML, err := getManifestYAML(hashed)
+	if err != nil {
+		return err
+	}
+	d.Set("manifest_yaml", manifestYAML)
 
 	return nil
 }
 
+// setManifestYAMLDiff sets the planned manifest_yaml, so the plan
+// shows the changed lines instead of the whole JSON manifest
+func setManifestYAMLDiff(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("manifest") {
+		return d.SetNewComputed("manifest_yaml")
+	}
+
+	manifest := d.Get("manifest").(string)
+	if manifest != "" {
+		sfs, err := getSensitiveFields(d.Get("sensitive_fields").([]interface{}))
+		if err != nil {
+			return err
+		}
+
+		// the same hashes as stored in the state
+		manifest, err = hashSensitiveFields(manifest, nil, sfs)
+		if err != nil {
+			return err
+		}
+	}
+
+	manifestYAML, err := getManifestYAML(manifest)
+	if err != nil {
+		return err
+	}
+
+	return d.SetNew("manifest_yaml", manifestYAML)
+}
+
+// suppressManifestDiff compares the manifest from the configuration to
+// the manifest stored in the state, with hashed sensitive fields if
+// hash_sensitive_fields is true, and suppresses semantically equal ones
+func suppressManifestDiff(k, old, new string, d *schema.ResourceData) bool {
+	if old == "" || new == "" {
+		return false
+	}
+
+	if d.Get("hash_sensitive_fields").(bool) {
+		sfs, err := getSensitiveFields(d.Get("sensitive_fields").([]interface{}))
+		if err != nil {
+			return false
+		}
+
+		new, err = hashSensitiveFields(new, nil, sfs)
+		if err != nil {
+			return false
+		}
+	}
+
+	return manifestsEqual(old, new)
+}
+
+// kustomizationResourceExists has no context aware version,
+// it is called during refresh and only makes short requests
 func kustomizationResourceExists(d *schema.ResourceData, m interface{}) (bool, error) {
-	km := newKManifest(m.(*Config).Mapper, m.(*Config).Client)
+	if isDeferred(d.Get("cluster"), m) {
+		return true, nil
+	}
+
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return false, logError(err)
+	}
+
+	km := m.(*Config).newKManifest(context.Background())
+
+	err = km.load([]byte(d.Get("manifest").(string)))
+	if err != nil {
+		return false, logError(err)
+	}
 
-	err := km.load([]byte(d.Get("manifest").(string)))
+	_, err = km.migrateAPIVersion()
 	if err != nil {
 		return false, logError(err)
 	}
@@ -162,7 +337,7 @@ func kustomizationResourceExists(d *schema.ResourceData, m interface{}) (bool, e
 		return false, logError(err)
 	}
 
-	_, err = km.apiGet(k8smetav1.GetOptions{})
+	_, err = km.apiGetCached()
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			return false, nil
@@ -178,17 +353,68 @@ func kustomizationResourceDiff(ctx context.Context, d *schema.ResourceDiff, m in
 		return nil
 	}
 
-	client := m.(*Config).Client
-	mapper := m.(*Config).Mapper
-	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
+	if err := setManifestYAMLDiff(d); err != nil {
+		return logError(err)
+	}
 
 	do, dm := d.GetChange("manifest")
 
-	kmm := newKManifest(mapper, client)
-	err := kmm.load([]byte(dm.(string)))
+	if do.(string) != "" && d.NewValueKnown("manifest") {
+		// does not require the cluster, so also runs if it's not known yet
+		recreate, err := nameOrNamespaceChanged(do.(string), dm.(string))
+		if err != nil {
+			return logError(err)
+		}
+		if recreate {
+			// if the resource name or namespace changes, we can't patch but have to destroy and re-create
+			d.ForceNew("manifest")
+			return nil
+		}
+	}
+
+	raw := d.GetRawConfig()
+	if isDeferred(d.Get("cluster"), m) || (!raw.IsNull() && hasUnknownValues(raw.GetAttr("cluster"))) {
+		// the server-side dry-runs can only run during apply
+		log.Printf("[WARN] skipping plan time dry-run, cluster not known until apply")
+
+		// the stored manifest depends on the cluster, e.g. a
+		// migrated apiVersion. The id is the object's uid and
+		// can only change if the plan re-creates the object.
+		return d.SetNewComputed("manifest_yaml")
+	}
+	m, err := getResourceConfig(d.Get("cluster"), m)
 	if err != nil {
 		return logError(err)
 	}
+
+	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
+
+	kmm := m.(*Config).newKManifest(ctx)
+	err = kmm.load([]byte(dm.(string)))
+	if err != nil {
+		return logError(err)
+	}
+	err = kmm.checkAPIVersionServed()
+	if err != nil {
+		return logError(err)
+	}
+
+	if m.(*Config).SchemaValidation {
+		if isCRD(kmm.resource) {
+			// validate custom resources of the CRD, before it's installed
+			if err := m.(*Config).CRDSchemas.add(kmm.resource); err != nil {
+				return logError(kmm.fmtErr(err))
+			}
+		}
+
+		// unlike the dry-run, also runs if the kind is not installed
+		// yet and returns all errors instead of only the first one
+		err = kmm.validateSchema(m.(*Config).CRDSchemas, m.(*Config).OpenAPI)
+		if err != nil {
+			return logError(err)
+		}
+	}
+
 	setLastAppliedConfig(kmm, gzipLastAppliedConfig)
 
 	_, err = kmm.mappings()
@@ -202,7 +428,7 @@ func kustomizationResourceDiff(ctx context.Context, d *schema.ResourceDiff, m in
 	if err != nil {
 		return logError(err)
 	}
-	if isNamespaced && kmm.namespace() == "" {
+	if isNamespaced && kmm.namespace() == "" && kmm.defaultNamespace == "" {
 		err = kmm.fmtErr(fmt.Errorf("is namespace scoped and must set metadata.namespace"))
 		return logError(err)
 	}
@@ -236,16 +462,19 @@ func kustomizationResourceDiff(ctx context.Context, d *schema.ResourceDiff, m in
 	}
 
 	// diffing for update
-	kmo := newKManifest(mapper, client)
+	kmo := m.(*Config).newKManifest(ctx)
 	err = kmo.load([]byte(do.(string)))
 	if err != nil {
 		return logError(err)
 	}
+	_, err = kmo.migrateAPIVersion()
+	if err != nil {
+		return logError(err)
+	}
 	setLastAppliedConfig(kmo, gzipLastAppliedConfig)
 
-	if kmo.name() != kmm.name() || kmo.namespace() != kmm.namespace() {
-		// if the resource name or namespace changes, we can't patch but have to destroy and re-create
-		d.ForceNew("manifest")
+	if d.HasChanges("cluster.0.kubeconfig_raw", "cluster.0.context", "cluster.0.host") {
+		// the object is re-created in the new cluster, there is nothing to patch
 		return nil
 	}
 
@@ -258,146 +487,253 @@ func kustomizationResourceDiff(ctx context.Context, d *schema.ResourceDiff, m in
 
 	_, err = kmm.apiPatch(pt, p, dryRunPatch)
 	if err != nil {
-		// Handle specific invalid errors
-		if k8serrors.IsInvalid(err) {
-			as := err.(k8serrors.APIStatus).Status()
+		if requiresRecreate(err) {
+			d.ForceNew("manifest")
+			return nil
+		}
 
-			// ForceNew only when exact single cause
-			if len(as.Details.Causes) == 1 {
-				msg := as.Details.Causes[0].Message
+		return logError(err)
+	}
 
-				// if cause is immutable field force a delete and re-create plan
-				if k8serrors.HasStatusCause(err, k8smetav1.CauseTypeFieldValueInvalid) && strings.HasSuffix(msg, ": field is immutable") == true {
-					d.ForceNew("manifest")
-					return nil
-				}
+	return nil
+}
 
-				// if cause is statefulset forbidden fields error force a delete and re-create plan
-				if k8serrors.HasStatusCause(err, k8smetav1.CauseType(field.ErrorTypeForbidden)) && strings.HasPrefix(msg, "Forbidden: updates to statefulset spec for fields") == true {
-					d.ForceNew("manifest")
-					return nil
-				}
+// nameOrNamespaceChanged returns true if the manifests old and new
+// are for objects with a different name or namespace
+func nameOrNamespaceChanged(old string, new string) (bool, error) {
+	kmo := &kManifest{}
+	if err := kmo.load([]byte(old)); err != nil {
+		return false, err
+	}
 
-				// if cause is cannot change roleRef force a delete and re-create plan
-				if k8serrors.HasStatusCause(err, k8smetav1.CauseTypeFieldValueInvalid) && strings.HasSuffix(msg, ": cannot change roleRef") == true {
-					d.ForceNew("manifest")
-					return nil
-				}
+	kmm := &kManifest{}
+	if err := kmm.load([]byte(new)); err != nil {
+		return false, err
+	}
 
-				// if cause is updates to storage class provisioner or parameters are forbidden force a delete and re-create plan
-				if k8serrors.HasStatusCause(err, k8smetav1.CauseType(field.ErrorTypeForbidden)) {
-					if strings.HasSuffix(msg, ": updates to provisioner are forbidden.") || strings.HasPrefix(msg, "Forbidden: updates to parameters are forbidden") {
-						d.ForceNew("manifest")
-						return nil
-					}
-				}
+	return kmo.name() != kmm.name() || kmo.namespace() != kmm.namespace(), nil
+}
 
-			}
-		}
+// requiresRecreate returns true if a patch failed because of a change
+// the API server does not allow in-place and that requires a delete and re-create
+func requiresRecreate(err error) bool {
+	// Handle specific invalid errors
+	if !k8serrors.IsInvalid(err) {
+		return false
+	}
 
-		return logError(err)
+	as := err.(k8serrors.APIStatus).Status()
+
+	// ForceNew only when exact single cause
+	if len(as.Details.Causes) != 1 {
+		return false
 	}
 
-	return nil
+	msg := as.Details.Causes[0].Message
+
+	// if cause is immutable field force a delete and re-create plan
+	if k8serrors.HasStatusCause(err, k8smetav1.CauseTypeFieldValueInvalid) && strings.HasSuffix(msg, ": field is immutable") == true {
+		return true
+	}
+
+	// if cause is statefulset forbidden fields error force a delete and re-create plan
+	if k8serrors.HasStatusCause(err, k8smetav1.CauseType(field.ErrorTypeForbidden)) && strings.HasPrefix(msg, "Forbidden: updates to statefulset spec for fields") == true {
+		return true
+	}
+
+	// if cause is cannot change roleRef force a delete and re-create plan
+	if k8serrors.HasStatusCause(err, k8smetav1.CauseTypeFieldValueInvalid) && strings.HasSuffix(msg, ": cannot change roleRef") == true {
+		return true
+	}
+
+	// if cause is updates to storage class provisioner or parameters are forbidden force a delete and re-create plan
+	if k8serrors.HasStatusCause(err, k8smetav1.CauseType(field.ErrorTypeForbidden)) {
+		if strings.HasSuffix(msg, ": updates to provisioner are forbidden.") || strings.HasPrefix(msg, "Forbidden: updates to parameters are forbidden") {
+			return true
+		}
+	}
+
+	return false
 }
 
-func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*Config).Client
-	mapper := m.(*Config).Mapper
+func kustomizationResourceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
 	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
 
 	do, dm := d.GetChange("manifest")
 
-	kmo := newKManifest(mapper, client)
-	err := kmo.load([]byte(do.(string)))
+	kmo := m.(*Config).newKManifest(ctx)
+	err = kmo.load([]byte(do.(string)))
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
 	}
 
-	kmm := newKManifest(mapper, client)
+	kmm := m.(*Config).newKManifest(ctx)
 	err = kmm.load([]byte(dm.(string)))
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
 	}
 
-	if !d.HasChange("manifest") && !d.HasChange("wait") {
-		return logError(kmm.fmtErr(
+	// patch through the served version, if the version of the state
+	// is removed, but never send the config under another version
+	if _, err = kmo.migrateAPIVersion(); err != nil {
+		return diag.FromErr(logError(err))
+	}
+	if err = kmm.checkAPIVersionServed(); err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	if !d.HasChanges("manifest", "wait", "triggers", "hash_sensitive_fields", "sensitive_fields", "cluster") {
+		return diag.FromErr(logError(kmm.fmtErr(
 			errors.New("update called without diff"),
-		))
+		)))
+	}
+
+	var resp *k8sunstructured.Unstructured
+	switch {
+	case d.HasChanges("manifest", "wait"):
+		// restart in the same patch, to roll out only once
+		resp, err = patchManifest(kmo, kmm, m, d.Timeout(schema.TimeoutUpdate), d.Get("wait").(bool), d.HasChange("triggers"))
+	case d.HasChange("triggers"):
+		resp, err = restartManifest(kmm, d.Timeout(schema.TimeoutUpdate), d.Get("wait").(bool))
+	default:
+		// only the state changes, e.g. which fields are
+		// hashed or the credentials of the cluster
+		resp, err = kmm.apiGet(k8smetav1.GetOptions{})
 	}
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	id := string(resp.GetUID())
+	d.SetId(id)
+
+	err = setManifest(d, resp, gzipLastAppliedConfig)
+	if err != nil {
+		return diag.FromErr(logError(kmm.fmtErr(err)))
+	}
+
+	return kustomizationResourceRead(ctx, d, m)
+}
+
+func patchManifest(kmo *kManifest, kmm *kManifest, m interface{}, t time.Duration, wait bool, restart bool) (resp *k8sunstructured.Unstructured, err error) {
+	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
 
 	setLastAppliedConfig(kmo, gzipLastAppliedConfig)
 	setLastAppliedConfig(kmm, gzipLastAppliedConfig)
 
 	pt, p, err := kmm.apiPreparePatch(kmo, false)
 	if err != nil {
-		return logError(err)
+		return nil, err
+	}
+
+	if restart && kmm.isRestartable() {
+		p, err = addRestartedAt(p)
+		if err != nil {
+			return nil, kmm.fmtErr(fmt.Errorf("restart failed: %s", err))
+		}
 	}
 
-	resp, err := kmm.apiPatch(pt, p, k8smetav1.PatchOptions{})
+	resp, err = kmm.apiPatch(pt, p, k8smetav1.PatchOptions{})
 	if err != nil {
-		return logError(err)
+		return nil, err
 	}
 
-	if d.Get("wait").(bool) {
-		if err = kmm.waitCreatedOrUpdated(d.Timeout(schema.TimeoutUpdate)); err != nil {
-			return logError(err)
+	if wait {
+		if err = kmm.waitCreatedOrUpdated(t); err != nil {
+			return nil, err
 		}
 	}
 
-	id := string(resp.GetUID())
-	d.SetId(id)
+	return resp, nil
+}
 
-	d.Set("manifest", getLastAppliedConfig(resp, gzipLastAppliedConfig))
+// restartManifest rolls out new pods for kinds with a pod template
+// and otherwise returns the current object unchanged
+func restartManifest(km *kManifest, t time.Duration, wait bool) (resp *k8sunstructured.Unstructured, err error) {
+	if !km.isRestartable() {
+		log.Printf("[DEBUG] %q: triggers changed, but kind does not support restarts", km.id().string())
+		return km.apiGet(k8smetav1.GetOptions{})
+	}
+
+	resp, err = km.apiRestart(k8smetav1.PatchOptions{})
+	if err != nil {
+		return nil, err
+	}
 
-	return kustomizationResourceRead(d, m)
+	if wait {
+		if err = km.waitCreatedOrUpdated(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
 }
 
-func kustomizationResourceDelete(d *schema.ResourceData, m interface{}) error {
-	client := m.(*Config).Client
-	mapper := m.(*Config).Mapper
+func kustomizationResourceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	m, err := getResourceConfig(d.Get("cluster"), m)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
 
-	km := newKManifest(mapper, client)
+	km := m.(*Config).newKManifest(ctx)
 
-	err := parseResourceData(km, d.Get("manifest").(string))
+	err = parseResourceData(km, d.Get("manifest").(string))
 	if err != nil {
-		return logError(err)
+		return diag.FromErr(logError(err))
 	}
 
+	err = deleteManifest(km, d.Timeout(schema.TimeoutDelete))
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	d.SetId("")
+
+	return nil
+}
+
+func deleteManifest(km *kManifest, t time.Duration) error {
 	// look for all versions of the GroupKind in case the resource uses a
 	// version that is no longer current
-	_, err = km.mappings()
+	_, err := km.mappings()
 	if err != nil {
 		if k8smeta.IsNoMatchError(err) {
 			// If the Kind does not exist in the K8s API,
 			// the resource can't exist either
 			return nil
 		}
-		return logError(km.fmtErr(err))
+		return km.fmtErr(err)
+	}
+
+	// delete through a served version of the GroupKind
+	_, err = km.migrateAPIVersion()
+	if err != nil {
+		return err
 	}
 
 	err = km.apiDelete(k8smetav1.DeleteOptions{})
 	if err != nil {
 		// Consider not found during deletion a success
 		if k8serrors.IsNotFound(err) {
-			d.SetId("")
 			return nil
 		}
 
-		return logError(err)
-	}
-
-	err = km.waitDeleted(d.Timeout(schema.TimeoutDelete))
-	if err != nil {
-		return logError(err)
+		return err
 	}
 
-	d.SetId("")
-
-	return nil
+	return km.waitDeleted(t)
 }
 
-func kustomizationResourceImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+func kustomizationResourceImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if m.(*Config).Deferred {
+		return nil, logError(fmt.Errorf("import requires a provider configuration that is known during plan"))
+	}
+
 	client := m.(*Config).Client
 	mapper := m.(*Config).Mapper
 	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
@@ -421,7 +757,7 @@ func kustomizationResourceImport(d *schema.ResourceData, m interface{}) ([]*sche
 	resp, err := client.
 		Resource(mappings[0].Resource).
 		Namespace(k.namespace).
-		Get(context.TODO(), k.name, k8smetav1.GetOptions{})
+		Get(ctx, k.name, k8smetav1.GetOptions{})
 	if err != nil {
 		return nil, logError(
 			fmt.Errorf("\"%s/%s/%s/%s\": %s", gk.Group, gk.Kind, k.namespace, k.name, err),
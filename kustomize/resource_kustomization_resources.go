@@ -0,0 +1,351 @@
+package kustomize
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+)
+
+func kustomizationResources() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: collectWarnings(kustomizationResourcesCreate),
+		ReadContext:   collectWarnings(kustomizationResourcesRead),
+		UpdateContext: collectWarnings(kustomizationResourcesUpdate),
+		DeleteContext: collectWarnings(kustomizationResourcesDelete),
+
+		Schema: map[string]*schema.Schema{
+			"manifests": &schema.Schema{
+				Type:             schema.TypeMap,
+				Required:         true,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				DiffSuppressFunc: suppressManifestsDiff,
+			},
+			"parallelism": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"wait": &schema.Schema{
+				Type:     schema.TypeBool,
+				Default:  false,
+				Optional: true,
+			},
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+	}
+}
+
+// appliedManifests collects the last applied config of objects
+// applied by concurrent graph walk callbacks
+type appliedManifests struct {
+	mu        sync.Mutex
+	manifests map[string]interface{}
+}
+
+func (a *appliedManifests) set(id string, lac string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.manifests[id] = lac
+}
+
+func (a *appliedManifests) remove(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.manifests, id)
+}
+
+// suppressManifestsDiff suppresses the diff of
+// semantically equal manifests of the same id
+func suppressManifestsDiff(k, old, new string, d *schema.ResourceData) bool {
+	if old == "" || new == "" {
+		return false
+	}
+
+	return manifestsEqual(old, new)
+}
+
+func getManifestsFromResourceData(v interface{}) map[string]string {
+	return convertMapStringInterfaceToMapStringString(v.(map[string]interface{}))
+}
+
+func getIDFromManifests(manifests map[string]string) string {
+	ids := []string{}
+	for id := range manifests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha512.New()
+	h.Write([]byte(strings.Join(ids, "\n")))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func kustomizationResourcesCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	manifests := getManifestsFromResourceData(d.Get("manifests"))
+
+	graph, err := dependencyGraph(manifests)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	applied := &appliedManifests{manifests: make(map[string]interface{})}
+	wait := d.Get("wait").(bool)
+	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
+
+	err = walkDependencyGraph(graph, d.Get("parallelism").(int), func(id string) error {
+		km := m.(*Config).newKManifest(ctx)
+		err := km.load([]byte(manifests[id]))
+		if err != nil {
+			return err
+		}
+
+		resp, err := createManifest(km, m, d.Timeout(schema.TimeoutCreate), wait)
+		if err != nil {
+			return err
+		}
+
+		applied.set(id, getLastAppliedConfig(resp, gzipLastAppliedConfig))
+
+		return nil
+	})
+
+	// keep track of the objects that were created, even if others failed
+	if len(applied.manifests) > 0 {
+		d.SetId(getIDFromManifests(manifests))
+		d.Set("manifests", applied.manifests)
+	}
+
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	return kustomizationResourcesRead(ctx, d, m)
+}
+
+func kustomizationResourcesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if m.(*Config).Deferred {
+		// keep the prior state until the provider configuration is known
+		log.Printf("[WARN] %s: skipping refresh, provider configuration not known until apply", d.Id())
+		return nil
+	}
+
+	manifests := getManifestsFromResourceData(d.Get("manifests"))
+	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
+
+	current := make(map[string]interface{})
+	for id, manifest := range manifests {
+		km := m.(*Config).newKManifest(ctx)
+		err := km.load([]byte(manifest))
+		if err != nil {
+			return diag.FromErr(logError(err))
+		}
+
+		migratedFrom, err := km.migrateAPIVersion()
+		if err != nil {
+			return diag.FromErr(logError(err))
+		}
+
+		resp, err := km.apiGetCached()
+		if err != nil {
+			if k8serrors.IsNotFound(err) || k8smeta.IsNoMatchError(err) {
+				// drop objects deleted outside of Terraform
+				// so the next plan re-creates them
+				continue
+			}
+			return diag.FromErr(logError(err))
+		}
+
+		if migratedFrom != "" {
+			// store the served apiVersion, so the plan
+			// shows an apiVersion upgrade instead of errors
+			err = migrateLastAppliedConfig(resp, km.resource.GetAPIVersion(), gzipLastAppliedConfig)
+			if err != nil {
+				return diag.FromErr(logError(km.fmtErr(err)))
+			}
+		}
+
+		current[id] = getLastAppliedConfig(resp, gzipLastAppliedConfig)
+	}
+
+	if len(current) == 0 {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("manifests", current)
+
+	return nil
+}
+
+func kustomizationResourcesUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	o, n := d.GetChange("manifests")
+	oldManifests := getManifestsFromResourceData(o)
+	newManifests := getManifestsFromResourceData(n)
+
+	graph, err := dependencyGraph(newManifests)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	applied := &appliedManifests{manifests: make(map[string]interface{})}
+	for id, manifest := range oldManifests {
+		applied.manifests[id] = manifest
+	}
+
+	parallelism := d.Get("parallelism").(int)
+	wait := d.Get("wait").(bool)
+	waitChanged := d.HasChange("wait")
+	gzipLastAppliedConfig := m.(*Config).GzipLastAppliedConfig
+
+	err = walkDependencyGraph(graph, parallelism, func(id string) error {
+		kmm := m.(*Config).newKManifest(ctx)
+		err := kmm.load([]byte(newManifests[id]))
+		if err != nil {
+			return err
+		}
+
+		old, exists := oldManifests[id]
+		if exists && old == newManifests[id] && !waitChanged {
+			return nil
+		}
+
+		if !exists {
+			resp, err := createManifest(kmm, m, d.Timeout(schema.TimeoutUpdate), wait)
+			if err != nil {
+				return err
+			}
+
+			applied.set(id, getLastAppliedConfig(resp, gzipLastAppliedConfig))
+			return nil
+		}
+
+		kmo := m.(*Config).newKManifest(ctx)
+		err = kmo.load([]byte(old))
+		if err != nil {
+			return err
+		}
+
+		// patch through the served version, if the version of the state
+		// is removed, but never send the config under another version
+		if _, err = kmo.migrateAPIVersion(); err != nil {
+			return err
+		}
+		if err = kmm.checkAPIVersionServed(); err != nil {
+			return err
+		}
+
+		resp, err := patchManifest(kmo, kmm, m, d.Timeout(schema.TimeoutUpdate), wait, false)
+		if err != nil {
+			if !requiresRecreate(err) {
+				return err
+			}
+
+			// the change can not be applied in-place
+			err = deleteManifest(kmo, d.Timeout(schema.TimeoutUpdate))
+			if err != nil {
+				return err
+			}
+			applied.remove(id)
+
+			kmm = m.(*Config).newKManifest(ctx)
+			err = kmm.load([]byte(newManifests[id]))
+			if err != nil {
+				return err
+			}
+
+			resp, err = createManifest(kmm, m, d.Timeout(schema.TimeoutUpdate), wait)
+			if err != nil {
+				return err
+			}
+		}
+
+		applied.set(id, getLastAppliedConfig(resp, gzipLastAppliedConfig))
+
+		return nil
+	})
+	if err != nil {
+		d.Set("manifests", applied.manifests)
+		return diag.FromErr(logError(err))
+	}
+
+	// prune objects that are no longer part of manifests
+	removed := make(map[string]string)
+	for id, manifest := range oldManifests {
+		if _, ok := newManifests[id]; !ok {
+			removed[id] = manifest
+		}
+	}
+
+	err = deleteManifests(ctx, removed, applied, parallelism, d.Timeout(schema.TimeoutUpdate), m)
+	d.Set("manifests", applied.manifests)
+	if err != nil {
+		return diag.FromErr(logError(err))
+	}
+
+	return kustomizationResourcesRead(ctx, d, m)
+}
+
+func kustomizationResourcesDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	manifests := getManifestsFromResourceData(d.Get("manifests"))
+
+	applied := &appliedManifests{manifests: make(map[string]interface{})}
+	for id, manifest := range manifests {
+		applied.manifests[id] = manifest
+	}
+
+	err := deleteManifests(ctx, manifests, applied, d.Get("parallelism").(int), d.Timeout(schema.TimeoutDelete), m)
+	if err != nil {
+		d.Set("manifests", applied.manifests)
+		return diag.FromErr(logError(err))
+	}
+
+	d.SetId("")
+
+	return nil
+}
+
+// deleteManifests deletes manifests in reverse dependency order
+// and removes every deleted object from applied
+func deleteManifests(ctx context.Context, manifests map[string]string, applied *appliedManifests, parallelism int, t time.Duration, m interface{}) error {
+	graph, err := dependencyGraph(manifests)
+	if err != nil {
+		return err
+	}
+
+	return walkDependencyGraph(reverseDependencyGraph(graph), parallelism, func(id string) error {
+		km := m.(*Config).newKManifest(ctx)
+		err := km.load([]byte(manifests[id]))
+		if err != nil {
+			return fmt.Errorf("%q: %s", id, err)
+		}
+
+		err = deleteManifest(km, t)
+		if err != nil {
+			return err
+		}
+
+		applied.remove(id)
+
+		return nil
+	})
+}
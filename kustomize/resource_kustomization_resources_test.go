@@ -0,0 +1,56 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccResourceKustomizationResources_basic(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			//
+			//
+			// Applying all objects of the build in dependency order
+			{
+				Config: testAccResourceKustomizationResourcesConfig_basic("test_kustomizations/basic/initial"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resources.test", "id"),
+					resource.TestCheckResourceAttr("kustomization_resources.test", "manifests.%", "4"),
+				),
+			},
+			//
+			//
+			// Applying modified config adding another deployment to the namespace
+			{
+				Config: testAccResourceKustomizationResourcesConfig_basic("test_kustomizations/basic/modified"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resources.test", "id"),
+					resource.TestCheckResourceAttr("kustomization_resources.test", "manifests.%", "5"),
+				),
+			},
+			//
+			//
+			// Reverting back to initial config with only one deployment
+			{
+				Config: testAccResourceKustomizationResourcesConfig_basic("test_kustomizations/basic/initial"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resources.test", "id"),
+					resource.TestCheckResourceAttr("kustomization_resources.test", "manifests.%", "4"),
+				),
+			},
+		},
+	})
+}
+
+func testAccResourceKustomizationResourcesConfig_basic(path string) string {
+	return testAccDataSourceKustomizationConfig_basic(path) + `
+resource "kustomization_resources" "test" {
+	manifests   = data.kustomization_build.test.manifests
+	parallelism = 2
+}
+`
+}
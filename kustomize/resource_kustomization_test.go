@@ -971,11 +971,11 @@ resource "kustomization_resource" "ns" {
 }
 
 resource "kustomization_resource" "sec_sa_token" {
-	manifest = data.kustomization_build.test.manifests["_/Secret/test-secret-sa-token/test-sa-token"]
+	manifest = data.kustomization_build.test.sensitive_manifests["_/Secret/test-secret-sa-token/test-sa-token"]
 }
 
 resource "kustomization_resource" "sec_default" {
-	manifest = data.kustomization_build.test.manifests["_/Secret/test-secret-sa-token/test"]
+	manifest = data.kustomization_build.test.sensitive_manifests["_/Secret/test-secret-sa-token/test"]
 }
 
 resource "time_sleep" "garbage_collection" {
@@ -1046,6 +1046,166 @@ resource "kustomization_resource" "dep1" {
 //
 // Test check functions
 
+// Triggers test
+func TestAccResourceKustomization_triggers(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			//
+			//
+			// Applying initial config with a trigger
+			{
+				Config: testAccResourceKustomizationConfig_triggers("test_kustomizations/basic/initial", "initial"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resource.dep1", "id"),
+					testAccCheckPodTemplateAnnotationAbsent("kustomization_resource.dep1", restartedAtAnnotation),
+				),
+			},
+			//
+			//
+			// Changing the trigger restarts the deployment
+			{
+				Config: testAccResourceKustomizationConfig_triggers("test_kustomizations/basic/initial", "modified"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resource.dep1", "id"),
+					resource.TestCheckResourceAttr("kustomization_resource.dep1", "triggers.secret", "modified"),
+					testAccCheckPodTemplateAnnotationPresent("kustomization_resource.dep1", restartedAtAnnotation),
+				),
+			},
+			//
+			//
+			// The restart annotation is not drift
+			{
+				Config:   testAccResourceKustomizationConfig_triggers("test_kustomizations/basic/initial", "modified"),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func testAccResourceKustomizationConfig_triggers(path string, trigger string) string {
+	return testAccDataSourceKustomizationConfig_basic(path) + fmt.Sprintf(`
+resource "kustomization_resource" "ns" {
+	manifest = data.kustomization_build.test.manifests["_/Namespace/_/test-basic"]
+}
+
+resource "kustomization_resource" "dep1" {
+	manifest = data.kustomization_build.test.manifests["apps/Deployment/test-basic/test"]
+
+	triggers = {
+		secret = "%s"
+	}
+
+	depends_on = [kustomization_resource.ns]
+}
+`, trigger)
+}
+
+// Hash sensitive fields test
+func TestAccResourceKustomization_hashSensitiveFields(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			//
+			//
+			// Applying initial config without hashing
+			{
+				Config: testAccResourceKustomizationConfig_hashSensitiveFields("test_kustomizations/basic/initial", false, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resource.ns", "id"),
+					resource.TestCheckResourceAttr("kustomization_resource.ns", "hash_sensitive_fields", "false"),
+				),
+			},
+			//
+			//
+			// Enabling hashing only updates the state
+			{
+				Config: testAccResourceKustomizationConfig_hashSensitiveFields("test_kustomizations/basic/initial", true, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resource.ns", "id"),
+					resource.TestCheckResourceAttr("kustomization_resource.ns", "hash_sensitive_fields", "true"),
+				),
+			},
+			//
+			//
+			// Changing the sensitive fields only updates the state
+			{
+				Config: testAccResourceKustomizationConfig_hashSensitiveFields("test_kustomizations/basic/initial", true, "_/ConfigMap:data"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("kustomization_resource.ns", "id"),
+					resource.TestCheckResourceAttr("kustomization_resource.ns", "sensitive_fields.0", "_/ConfigMap:data"),
+				),
+			},
+		},
+	})
+}
+
+func testAccResourceKustomizationConfig_hashSensitiveFields(path string, hash bool, field string) string {
+	sensitiveFields := "[]"
+	if field != "" {
+		sensitiveFields = fmt.Sprintf("[%q]", field)
+	}
+
+	return testAccDataSourceKustomizationConfig_basic(path) + fmt.Sprintf(`
+resource "kustomization_resource" "ns" {
+	manifest = data.kustomization_build.test.manifests["_/Namespace/_/test-basic"]
+
+	hash_sensitive_fields = %t
+	sensitive_fields      = %s
+}
+`, hash, sensitiveFields)
+}
+
+func testAccCheckPodTemplateAnnotationPresent(n string, k string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		u, err := getResourceFromTestState(s, n)
+		if err != nil {
+			return err
+		}
+
+		_, ok, _ := k8sunstructured.NestedString(u.Object, "spec", "template", "metadata", "annotations", k)
+		if ok {
+			return fmt.Errorf("Pod template annotation %s unexpectedly part of manifest in state", k)
+		}
+
+		resp, err := getResourceFromK8sAPI(u)
+		if err != nil {
+			return err
+		}
+
+		_, ok, _ = k8sunstructured.NestedString(resp.Object, "spec", "template", "metadata", "annotations", k)
+		if !ok {
+			return fmt.Errorf("Pod template annotation missing: %s", k)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckPodTemplateAnnotationAbsent(n string, k string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		u, err := getResourceFromTestState(s, n)
+		if err != nil {
+			return err
+		}
+
+		resp, err := getResourceFromK8sAPI(u)
+		if err != nil {
+			return err
+		}
+
+		_, ok, _ := k8sunstructured.NestedString(resp.Object, "spec", "template", "metadata", "annotations", k)
+		if ok {
+			return fmt.Errorf("Unexpected pod template annotation exists: %s", k)
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckDeploymentPurged(n string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		client := testAccProvider.Meta().(*Config).Client
@@ -2,9 +2,9 @@ package kustomize
 
 import (
 	"context"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smeta "k8s.io/apimachinery/pkg/api/meta"
@@ -12,16 +12,16 @@ import (
 	"k8s.io/client-go/dynamic"
 )
 
-func waitForGVKCreated(d *schema.ResourceData, client dynamic.Interface, mapping *k8smeta.RESTMapping, namespace string, name string) (interface{}, error) {
+func waitForGVKCreated(ctx context.Context, t time.Duration, client dynamic.Interface, mapping *k8smeta.RESTMapping, namespace string, name string) (interface{}, error) {
 	stateConf := &resource.StateChangeConf{
 		Target:  []string{"existing"},
 		Pending: []string{"pending"},
-		Timeout: d.Timeout(schema.TimeoutCreate),
+		Timeout: t,
 		Refresh: func() (interface{}, string, error) {
 			resp, err := client.
 				Resource(mapping.Resource).
 				Namespace(namespace).
-				Get(context.TODO(), name, k8smetav1.GetOptions{})
+				Get(ctx, name, k8smetav1.GetOptions{})
 			if err != nil {
 				if k8serrors.IsNotFound(err) {
 					return nil, "pending", nil
@@ -33,5 +33,5 @@ func waitForGVKCreated(d *schema.ResourceData, client dynamic.Interface, mapping
 		},
 	}
 
-	return stateConf.WaitForState()
+	return stateConf.WaitForStateContext(ctx)
 }
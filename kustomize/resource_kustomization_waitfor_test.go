@@ -0,0 +1,24 @@
+package kustomize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestWaitForGVKCreatedCanceled(t *testing.T) {
+	client := testReadCacheClient()
+	mapping := &k8smeta.RESTMapping{Resource: testConfigMapGVR}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := waitForGVKCreated(ctx, 5*time.Minute, client, mapping, "test", "missing")
+	assert.NotEqual(t, nil, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
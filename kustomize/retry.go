@@ -0,0 +1,110 @@
+package kustomize
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	retryInitialInterval = 500 * time.Millisecond
+	retryMaxInterval     = 30 * time.Second
+)
+
+// retryPolicy retries API calls that failed with transient errors
+// using exponential backoff with full jitter
+type retryPolicy struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+
+	initialInterval time.Duration
+	maxInterval     time.Duration
+}
+
+func newRetryPolicy(maxAttempts int, maxElapsed time.Duration) *retryPolicy {
+	return &retryPolicy{
+		maxAttempts:     maxAttempts,
+		maxElapsed:      maxElapsed,
+		initialInterval: retryInitialInterval,
+		maxInterval:     retryMaxInterval,
+	}
+}
+
+// isRetryable returns true for errors that are likely to succeed
+// when retried, e.g. throttling, server errors like etcd leader
+// changes and optimistic lock conflicts. Errors caused by the
+// request itself, like validation errors, are never retried.
+func isRetryable(err error) bool {
+	code := int32(0)
+	if status, ok := err.(k8serrors.APIStatus); ok {
+		code = status.Status().Code
+	}
+
+	// not implemented by the server, will never succeed
+	if code == 501 {
+		return false
+	}
+
+	switch {
+	case k8serrors.IsTooManyRequests(err),
+		k8serrors.IsConflict(err),
+		k8serrors.IsInternalError(err),
+		k8serrors.IsServerTimeout(err),
+		k8serrors.IsServiceUnavailable(err),
+		k8serrors.IsTimeout(err):
+		return true
+	}
+
+	return code >= 500
+}
+
+// backoff returns the wait before the retry following attempt
+func (rp *retryPolicy) backoff(attempt int, err error) time.Duration {
+	interval := rp.initialInterval << uint(attempt-1)
+	if interval <= 0 || interval > rp.maxInterval {
+		interval = rp.maxInterval
+	}
+
+	wait := time.Duration(rand.Int63n(int64(interval) + 1))
+
+	// respect the Retry-After header of throttled requests
+	if seconds, ok := k8serrors.SuggestsClientDelay(err); ok {
+		if min := time.Duration(seconds) * time.Second; wait < min {
+			wait = min
+		}
+	}
+
+	return wait
+}
+
+// do calls fn until it succeeds, fails with an error that is not
+// retryable, or the max attempts or max elapsed time are reached
+func (rp *retryPolicy) do(ctx context.Context, op string, fn func() error) error {
+	if rp == nil {
+		return fn()
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) || attempt >= rp.maxAttempts {
+			return err
+		}
+
+		wait := rp.backoff(attempt, err)
+		if rp.maxElapsed > 0 && time.Since(start)+wait > rp.maxElapsed {
+			return err
+		}
+
+		log.Printf("[DEBUG] %s: attempt %d of %d failed, retrying in %s: %s", op, attempt, rp.maxAttempts, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testRetryGR = k8sschema.GroupResource{Resource: "configmaps"}
+
+func testRetryPolicy(maxAttempts int, maxElapsed time.Duration) *retryPolicy {
+	rp := newRetryPolicy(maxAttempts, maxElapsed)
+	rp.initialInterval = time.Millisecond
+	rp.maxInterval = 10 * time.Millisecond
+	return rp
+}
+
+func TestIsRetryable(t *testing.T) {
+	retryable := []error{
+		k8serrors.NewTooManyRequests("throttled", 0),
+		k8serrors.NewConflict(testRetryGR, "test", errors.New("modified")),
+		k8serrors.NewInternalError(errors.New("etcd leader changed")),
+		k8serrors.NewServerTimeout(testRetryGR, "create", 0),
+		k8serrors.NewServiceUnavailable("unavailable"),
+		k8serrors.NewTimeoutError("timeout", 0),
+		k8serrors.NewGenericServerResponse(502, "get", testRetryGR, "test", "", 0, true),
+	}
+	for _, err := range retryable {
+		assert.True(t, isRetryable(err), err.Error())
+	}
+
+	notRetryable := []error{
+		nil,
+		errors.New("not an API error"),
+		k8serrors.NewInvalid(k8sschema.GroupKind{Kind: "ConfigMap"}, "test", nil),
+		k8serrors.NewBadRequest("bad request"),
+		k8serrors.NewNotFound(testRetryGR, "test"),
+		k8serrors.NewAlreadyExists(testRetryGR, "test"),
+		k8serrors.NewForbidden(testRetryGR, "test", errors.New("forbidden")),
+		k8serrors.NewGenericServerResponse(501, "get", testRetryGR, "test", "", 0, true),
+	}
+	for _, err := range notRetryable {
+		assert.False(t, isRetryable(err), "%v", err)
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	rp := testRetryPolicy(5, 0)
+
+	// succeeds after transient errors
+	calls := 0
+	err := rp.do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return k8serrors.NewInternalError(errors.New("etcd leader changed"))
+		}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, calls)
+
+	// validation errors are returned immediately
+	calls = 0
+	err = rp.do(context.Background(), "test", func() error {
+		calls++
+		return k8serrors.NewInvalid(k8sschema.GroupKind{Kind: "ConfigMap"}, "test", nil)
+	})
+	assert.True(t, k8serrors.IsInvalid(err))
+	assert.Equal(t, 1, calls)
+
+	// gives up after max attempts
+	calls = 0
+	err = rp.do(context.Background(), "test", func() error {
+		calls++
+		return k8serrors.NewTooManyRequests("throttled", 0)
+	})
+	assert.True(t, k8serrors.IsTooManyRequests(err))
+	assert.Equal(t, 5, calls)
+}
+
+func TestRetryPolicyDoMaxElapsed(t *testing.T) {
+	rp := testRetryPolicy(100, 50*time.Millisecond)
+
+	start := time.Now()
+	calls := 0
+	err := rp.do(context.Background(), "test", func() error {
+		calls++
+		return k8serrors.NewServiceUnavailable("unavailable")
+	})
+	assert.True(t, k8serrors.IsServiceUnavailable(err))
+	assert.Less(t, calls, 100)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	rp := testRetryPolicy(100, 0)
+	rp.initialInterval = time.Hour
+	rp.maxInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := rp.do(ctx, "test", func() error {
+		calls++
+		// throttled requests wait at least Retry-After seconds
+		return k8serrors.NewTooManyRequests("throttled", 60)
+	})
+	assert.True(t, k8serrors.IsTooManyRequests(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyNil(t *testing.T) {
+	var rp *retryPolicy
+
+	calls := 0
+	err := rp.do(context.Background(), "test", func() error {
+		calls++
+		return k8serrors.NewInternalError(errors.New("etcd leader changed"))
+	})
+	assert.True(t, k8serrors.IsInternalError(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestAPICreateRetryAlreadyExists(t *testing.T) {
+	const configMap = `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}, "data": {"key": "value"}}`
+
+	for _, tc := range []struct {
+		name    string
+		created bool
+		live    string
+	}{
+		// the first attempt created the object, but timed out
+		{name: "created", created: true},
+		// the object was created by someone else
+		{name: "other", live: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}, "data": {"key": "other"}}`},
+	} {
+		live := []string{}
+		if tc.live != "" {
+			live = append(live, tc.live)
+		}
+		mapper, client := testDiffClient(t, live...)
+
+		km := newKManifest(mapper, client)
+		km.retry = testRetryPolicy(3, 0)
+		err := km.load([]byte(configMap))
+		assert.Equal(t, nil, err)
+		setLastAppliedConfig(km, false)
+
+		calls := 0
+		client.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			calls++
+			if calls > 1 {
+				return false, nil, nil
+			}
+			if tc.created {
+				err := client.Tracker().Create(testConfigMapGVR, km.resource.DeepCopy(), "test")
+				assert.Equal(t, nil, err)
+			}
+			return true, nil, k8serrors.NewServerTimeout(testConfigMapGVR.GroupResource(), "create", 0)
+		})
+
+		resp, err := km.apiCreate(k8smetav1.CreateOptions{})
+		if tc.created {
+			assert.Equal(t, nil, err, tc.name)
+			assert.Equal(t, "test", resp.GetName(), tc.name)
+		} else {
+			assert.True(t, k8serrors.IsAlreadyExists(err), tc.name)
+		}
+		assert.Equal(t, 2, calls, tc.name)
+	}
+}
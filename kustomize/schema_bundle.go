@@ -0,0 +1,138 @@
+package kustomize
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// schemas of the built-in kinds per Kubernetes version, generated
+// from the OpenAPI v2 document of the version by schemas/generate.go
+//
+//go:embed schemas/*.json.gz
+var bundledSchemaFiles embed.FS
+
+var bundledSchemas = struct {
+	mu   sync.Mutex
+	docs map[string]*openAPIDoc
+}{docs: make(map[string]*openAPIDoc)}
+
+// bundledSchemaVersions returns the bundled versions, oldest first
+func bundledSchemaVersions() (versions []*version.Version) {
+	files, _ := bundledSchemaFiles.ReadDir("schemas")
+	for _, f := range files {
+		v, err := version.ParseGeneric(strings.TrimSuffix(f.Name(), ".json.gz"))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].LessThan(versions[j])
+	})
+
+	return versions
+}
+
+// getBundledSchemaVersion returns the bundled version for kubeVersion,
+// the latest bundled version older than kubeVersion if there is no exact
+// match, or the latest bundled version if kubeVersion is empty. Only an
+// exact match is of the requested version, the latest bundled version
+// may be older than the cluster's.
+func getBundledSchemaVersion(kubeVersion string) (v string, exact bool, err error) {
+	versions := bundledSchemaVersions()
+	if len(versions) == 0 {
+		return "", false, fmt.Errorf("no bundled schemas")
+	}
+
+	if kubeVersion == "" {
+		latest := versions[len(versions)-1]
+		return fmt.Sprintf("v%d.%d", latest.Major(), latest.Minor()), false, nil
+	}
+
+	kv, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid Kubernetes version %q: %s", kubeVersion, err)
+	}
+
+	var match *version.Version
+	for _, bv := range versions {
+		if bv.Major() == kv.Major() && bv.Minor() <= kv.Minor() {
+			match = bv
+		}
+	}
+
+	if match == nil {
+		return "", false, fmt.Errorf("no bundled schemas for Kubernetes %q, oldest bundled version is v%d.%d", kubeVersion, versions[0].Major(), versions[0].Minor())
+	}
+
+	return fmt.Sprintf("v%d.%d", match.Major(), match.Minor()), match.Minor() == kv.Minor(), nil
+}
+
+// getBundledSchemas returns the parsed schemas of version v,
+// as returned by getBundledSchemaVersion
+func getBundledSchemas(v string) (*openAPIDoc, error) {
+	bundledSchemas.mu.Lock()
+	defer bundledSchemas.mu.Unlock()
+
+	if doc, ok := bundledSchemas.docs[v]; ok {
+		return doc, nil
+	}
+
+	data, err := bundledSchemaFiles.ReadFile(path.Join("schemas", v+".json.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseSwaggerDefinitions(data)
+	if err != nil {
+		return nil, fmt.Errorf("bundled schemas %s: %s", v, err)
+	}
+	bundledSchemas.docs[v] = doc
+
+	return doc, nil
+}
+
+// parseSwaggerDefinitions parses the definitions of an OpenAPI v2 document
+func parseSwaggerDefinitions(data []byte) (*openAPIDoc, error) {
+	swagger := struct {
+		Definitions map[string]*spec.Schema `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(data, &swagger); err != nil {
+		return nil, err
+	}
+
+	doc := &openAPIDoc{
+		components: make(map[string]*spec.Schema),
+		kinds:      make(map[k8sschema.GroupVersionKind]*spec.Schema),
+	}
+
+	for name, s := range swagger.Definitions {
+		doc.add(name, s)
+	}
+
+	return doc, nil
+}
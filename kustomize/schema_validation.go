@@ -0,0 +1,473 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// chains of refs are followed at most this deep, to not loop on
+// refs that refer to themselves, nested fields are limited by the object
+const schemaMaxRefDepth = 10
+
+// openAPISchemas looks up the schemas of kinds in the cluster's
+// OpenAPI v3 documents, fetching one document per group version
+// the first time one of its kinds is validated
+type openAPISchemas struct {
+	client openapi.Client
+
+	mu    sync.Mutex
+	paths map[string]openapi.GroupVersion
+	docs  map[string]*openAPIDoc
+}
+
+// openAPIDoc are the component schemas of one group version
+type openAPIDoc struct {
+	components map[string]*spec.Schema
+	kinds      map[k8sschema.GroupVersionKind]*spec.Schema
+}
+
+func newOpenAPISchemas(client openapi.Client) *openAPISchemas {
+	return &openAPISchemas{
+		client: client,
+		docs:   make(map[string]*openAPIDoc),
+	}
+}
+
+// openAPIPath returns the path of the OpenAPI v3 document of gv
+func openAPIPath(gv k8sschema.GroupVersion) string {
+	if gv.Group == "" {
+		return "api/" + gv.Version
+	}
+	return "apis/" + gv.Group + "/" + gv.Version
+}
+
+// get returns the document with the schema of gvk, or nil
+// if the cluster does not publish one for its group version.
+// Documents are fetched without holding the lock, so validations
+// of other group versions don't wait for the request.
+func (oas *openAPISchemas) get(gvk k8sschema.GroupVersionKind) (*openAPIDoc, error) {
+	path := openAPIPath(gvk.GroupVersion())
+
+	oas.mu.Lock()
+	paths := oas.paths
+	doc, ok := oas.docs[path]
+	oas.mu.Unlock()
+
+	if ok {
+		return doc, nil
+	}
+
+	if paths == nil {
+		var err error
+		paths, err = oas.client.Paths()
+		if err != nil {
+			return nil, err
+		}
+
+		oas.mu.Lock()
+		oas.paths = paths
+		oas.mu.Unlock()
+	}
+
+	gv, ok := paths[path]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := gv.Schema("application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err = parseOpenAPIDoc(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	oas.mu.Lock()
+	defer oas.mu.Unlock()
+
+	// keep the document of concurrent calls that finished first
+	if d, ok := oas.docs[path]; ok {
+		return d, nil
+	}
+	oas.docs[path] = doc
+
+	return doc, nil
+}
+
+func parseOpenAPIDoc(data []byte) (*openAPIDoc, error) {
+	o := &spec3.OpenAPI{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, err
+	}
+
+	doc := &openAPIDoc{
+		components: make(map[string]*spec.Schema),
+		kinds:      make(map[k8sschema.GroupVersionKind]*spec.Schema),
+	}
+
+	if o.Components == nil {
+		return doc, nil
+	}
+
+	for name, s := range o.Components.Schemas {
+		doc.add(name, s)
+	}
+
+	return doc, nil
+}
+
+// add adds the schema s named name, by its GVKs if it is a kind
+func (doc *openAPIDoc) add(name string, s *spec.Schema) {
+	doc.components[name] = s
+
+	gvks, ok := s.Extensions["x-kubernetes-group-version-kind"].([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, i := range gvks {
+		gvk, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _ := gvk["group"].(string)
+		version, _ := gvk["version"].(string)
+		kind, _ := gvk["kind"].(string)
+		doc.kinds[k8sschema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = s
+	}
+}
+
+// resolve returns the schema of ref, of OpenAPI v3 or v2 documents
+func (doc *openAPIDoc) resolve(ref string) *spec.Schema {
+	name := strings.TrimPrefix(ref, "#/components/schemas/")
+	name = strings.TrimPrefix(name, "#/definitions/")
+	return doc.components[name]
+}
+
+// crdSchemas are the schemas of the CRDs of the builds and resources
+// of the current run, so custom resources can be validated before
+// their CRD is installed. Each cluster has its own, so the CRD of
+// one cluster does not validate the custom resources of another.
+type crdSchemas struct {
+	mu    sync.Mutex
+	kinds map[k8sschema.GroupVersionKind]*spec.Schema
+}
+
+func newCRDSchemas() *crdSchemas {
+	return &crdSchemas{kinds: make(map[k8sschema.GroupVersionKind]*spec.Schema)}
+}
+
+func isCRD(u *k8sunstructured.Unstructured) bool {
+	return u.GroupVersionKind().GroupKind() == k8sschema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+}
+
+// add adds the schemas of all versions of crd
+func (cs *crdSchemas) add(crd *k8sunstructured.Unstructured) error {
+	group, _, _ := k8sunstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := k8sunstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := k8sunstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	for _, i := range versions {
+		v, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := k8sunstructured.NestedString(v, "name")
+		raw, ok, _ := k8sunstructured.NestedMap(v, "schema", "openAPIV3Schema")
+		if !ok {
+			continue
+		}
+
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+
+		s := &spec.Schema{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("CRD %q version %q: invalid schema: %s", crd.GetName(), name, err)
+		}
+
+		// fields every object has, that CRD schemas don't have to declare
+		if s.Properties == nil {
+			s.Properties = make(map[string]spec.Schema)
+		}
+		for _, p := range []string{"apiVersion", "kind"} {
+			if _, ok := s.Properties[p]; !ok {
+				s.Properties[p] = *spec.StringProperty()
+			}
+		}
+		s.Properties["metadata"] = spec.Schema{}
+
+		cs.mu.Lock()
+		cs.kinds[k8sschema.GroupVersionKind{Group: group, Version: name, Kind: kind}] = s
+		cs.mu.Unlock()
+	}
+
+	return nil
+}
+
+// addResMap adds the schemas of all CRDs in rm
+func (cs *crdSchemas) addResMap(rm resmap.ResMap) {
+	for _, r := range rm.Resources() {
+		if r.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+
+		m, err := r.Map()
+		if err != nil {
+			continue
+		}
+
+		u := &k8sunstructured.Unstructured{Object: m}
+		if !isCRD(u) {
+			continue
+		}
+
+		if err := cs.add(u); err != nil {
+			log.Printf("[DEBUG] schema validation: %s", err)
+		}
+	}
+}
+
+func (cs *crdSchemas) get(gvk k8sschema.GroupVersionKind) *spec.Schema {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.kinds[gvk]
+}
+
+// validateSchema validates the object of km against the schema of its
+// kind, from the CRDs of the current run or the cluster's OpenAPI v3.
+// It returns one error listing all errors, or nil if the schema
+// is not known.
+func (km *kManifest) validateSchema(crds *crdSchemas, schemas *openAPISchemas) error {
+	gvk := km.gvk()
+
+	var s *spec.Schema
+	resolve := func(string) *spec.Schema { return nil }
+
+	if crds != nil {
+		s = crds.get(gvk)
+	}
+
+	if s == nil && schemas != nil {
+		doc, err := schemas.get(gvk)
+		if err != nil {
+			log.Printf("[DEBUG] %q: skipping schema validation: %s", km.id().string(), err)
+			return nil
+		}
+		if doc != nil {
+			s = doc.kinds[gvk]
+			resolve = doc.resolve
+		}
+	}
+
+	if s == nil {
+		log.Printf("[DEBUG] %q: skipping schema validation, no schema for %q", km.id().string(), gvk.String())
+		return nil
+	}
+
+	errs := validateAgainstSchema(km.resource.Object, s, resolve)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return km.fmtErr(fmt.Errorf("schema validation failed:\n  - %s", strings.Join(errs, "\n  - ")))
+}
+
+// validateAgainstSchema returns the unknown fields and type errors of
+// obj, with their field paths, using resolve to look up refs of s
+func validateAgainstSchema(obj map[string]interface{}, s *spec.Schema, resolve func(ref string) *spec.Schema) []string {
+	v := &schemaValidator{resolve: resolve}
+	v.validate("", obj, s)
+
+	return v.errs
+}
+
+// schemaValidator reports unknown fields and type errors of an object,
+// other constraints, e.g. required fields, are left to the API server
+type schemaValidator struct {
+	resolve func(ref string) *spec.Schema
+	errs    []string
+}
+
+func (v *schemaValidator) errorf(path string, format string, a ...interface{}) {
+	if path == "" {
+		path = "."
+	}
+	v.errs = append(v.errs, path+": "+fmt.Sprintf(format, a...))
+}
+
+// deref follows refs, including the allOf with a
+// single ref that wraps refs with a description
+func (v *schemaValidator) deref(s *spec.Schema) *spec.Schema {
+	for depth := 0; s != nil; depth++ {
+		if depth >= schemaMaxRefDepth {
+			return nil
+		}
+
+		if ref := s.Ref.String(); ref != "" {
+			s = v.resolve(ref)
+			continue
+		}
+
+		if len(s.AllOf) == 1 && len(s.Type) == 0 && len(s.Properties) == 0 {
+			s = &s.AllOf[0]
+			continue
+		}
+
+		break
+	}
+
+	return s
+}
+
+func (v *schemaValidator) validate(path string, value interface{}, s *spec.Schema) {
+	s = v.deref(s)
+	if s == nil || value == nil {
+		return
+	}
+
+	if b, _ := s.Extensions.GetBool("x-kubernetes-int-or-string"); b {
+		if !isSchemaInteger(value) && !isSchemaString(value) {
+			v.errorf(path, "expected integer or string, got %s", schemaTypeOf(value))
+		}
+		return
+	}
+
+	typ := ""
+	if len(s.Type) == 1 {
+		typ = s.Type[0]
+	} else if len(s.Type) == 0 && len(s.Properties) > 0 {
+		typ = "object"
+	}
+
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.errorf(path, "expected object, got %s", schemaTypeOf(value))
+			return
+		}
+		v.validateObject(path, obj, s)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			v.errorf(path, "expected array, got %s", schemaTypeOf(value))
+			return
+		}
+		if s.Items == nil || s.Items.Schema == nil {
+			return
+		}
+		for i, item := range list {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), item, s.Items.Schema)
+		}
+	case "string":
+		if !isSchemaString(value) {
+			v.errorf(path, "expected string, got %s", schemaTypeOf(value))
+		}
+	case "integer":
+		if !isSchemaInteger(value) {
+			v.errorf(path, "expected integer, got %s", schemaTypeOf(value))
+		}
+	case "number":
+		if !isSchemaInteger(value) && schemaTypeOf(value) != "number" {
+			v.errorf(path, "expected number, got %s", schemaTypeOf(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.errorf(path, "expected boolean, got %s", schemaTypeOf(value))
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(path string, obj map[string]interface{}, s *spec.Schema) {
+	preserveUnknown, _ := s.Extensions.GetBool("x-kubernetes-preserve-unknown-fields")
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		if ps, ok := s.Properties[k]; ok {
+			v.validate(p, obj[k], &ps)
+			continue
+		}
+
+		if ap := s.AdditionalProperties; ap != nil {
+			if ap.Schema != nil {
+				v.validate(p, obj[k], ap.Schema)
+				continue
+			}
+			if ap.Allows {
+				continue
+			}
+		}
+
+		// objects without properties are free-form
+		if preserveUnknown || len(s.Properties) == 0 {
+			continue
+		}
+
+		v.errorf(p, "unknown field %q", k)
+	}
+}
+
+// isUnknownFieldError returns true, if err of validateAgainstSchema
+// reports an unknown field, instead of a type error
+func isUnknownFieldError(err string) bool {
+	return strings.Contains(err, ": unknown field ")
+}
+
+func isSchemaString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+func isSchemaInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case int64, int:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	}
+	return false
+}
+
+func schemaTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, int, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
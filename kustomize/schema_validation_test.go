@@ -0,0 +1,210 @@
+package kustomize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/client-go/openapi/openapitest"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const testSchemaValidationCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: examples.example.com
+spec:
+  group: example.com
+  names:
+    kind: Example
+    plural: examples
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              replicas:
+                type: integer
+              port:
+                x-kubernetes-int-or-string: true
+              labels:
+                type: object
+                additionalProperties:
+                  type: string
+              config:
+                type: object
+                x-kubernetes-preserve-unknown-fields: true
+`
+
+func TestValidateSchemaOpenAPI(t *testing.T) {
+	schemas := newOpenAPISchemas(openapitest.NewEmbeddedFileClient())
+
+	km := &kManifest{}
+	err := km.load([]byte(`{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "test", "namespace": "test", "creationTimestamp": null, "labels": {"app": "test"}},
+		"spec": {
+			"replicas": 1,
+			"selector": {"matchLabels": {"app": "test"}},
+			"template": {
+				"metadata": {"labels": {"app": "test"}},
+				"spec": {
+					"containers": [{
+						"name": "test",
+						"image": "nginx",
+						"ports": [{"containerPort": 80}],
+						"resources": {"limits": {"cpu": "100m", "memory": 128}}
+					}]
+				}
+			}
+		}
+	}`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, km.validateSchema(nil, schemas))
+
+	err = km.load([]byte(`{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "test", "namespace": "test"},
+		"spec": {
+			"replicas": "one",
+			"selectr": {"matchLabels": {"app": "test"}},
+			"template": {
+				"spec": {
+					"containers": [{
+						"name": "test",
+						"image": "nginx",
+						"ports": [{"containerPort": "http"}],
+						"imagePullPolicy": ["Always"]
+					}]
+				}
+			}
+		}
+	}`))
+	assert.Equal(t, nil, err)
+
+	err = km.validateSchema(nil, schemas)
+	assert.Equal(t, `"apps/Deployment/test/test": schema validation failed:
+  - spec.replicas: expected integer, got string
+  - spec.selectr: unknown field "selectr"
+  - spec.template.spec.containers[0].imagePullPolicy: expected string, got array
+  - spec.template.spec.containers[0].ports[0].containerPort: expected integer, got string`, err.Error())
+
+	// kinds without a schema are not validated
+	err = km.load([]byte(`{"apiVersion": "example.com/v1", "kind": "Example", "metadata": {"name": "test"}, "spec": {"unknown": true}}`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, km.validateSchema(nil, schemas))
+}
+
+func TestValidateSchemaCRD(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("kustomization.yaml", []byte(`
+resources:
+- crd.yaml
+`))
+	fSys.WriteFile("crd.yaml", []byte(testSchemaValidationCRD))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, ".")
+	assert.Equal(t, nil, err)
+
+	crds := newCRDSchemas()
+	crds.addResMap(rm)
+
+	km := &kManifest{}
+	err = km.load([]byte(`{
+		"apiVersion": "example.com/v1",
+		"kind": "Example",
+		"metadata": {"name": "test", "namespace": "test", "labels": {"app": "test"}},
+		"spec": {
+			"replicas": 3,
+			"port": "http",
+			"labels": {"app": "test"},
+			"config": {"any": {"thing": true}}
+		}
+	}`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, km.validateSchema(crds, nil))
+
+	err = km.load([]byte(`{
+		"apiVersion": "example.com/v1",
+		"kind": "Example",
+		"metadata": {"name": "test", "namespace": "test"},
+		"spec": {
+			"replicas": 1.5,
+			"port": true,
+			"labels": {"app": 1},
+			"unknown": "field"
+		},
+		"status": {}
+	}`))
+	assert.Equal(t, nil, err)
+
+	err = km.validateSchema(crds, nil)
+	assert.Equal(t, `"example.com/Example/test/test": schema validation failed:
+  - spec.labels.app: expected string, got number
+  - spec.port: expected integer or string, got boolean
+  - spec.replicas: expected integer, got number
+  - spec.unknown: unknown field "unknown"
+  - status: unknown field "status"`, err.Error())
+}
+
+// blockingGroupVersion returns an empty document once unblocked
+type blockingGroupVersion struct {
+	unblock chan struct{}
+}
+
+func (gv blockingGroupVersion) Schema(contentType string) ([]byte, error) {
+	if gv.unblock != nil {
+		<-gv.unblock
+	}
+	return []byte(`{"openapi": "3.0.0"}`), nil
+}
+
+type testOpenAPIClient map[string]openapi.GroupVersion
+
+func (c testOpenAPIClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return c, nil
+}
+
+func TestOpenAPISchemasGetConcurrent(t *testing.T) {
+	unblock := make(chan struct{})
+	schemas := newOpenAPISchemas(testOpenAPIClient{
+		"apis/slow.example.com/v1": blockingGroupVersion{unblock: unblock},
+		"apis/fast.example.com/v1": blockingGroupVersion{},
+	})
+
+	slow := make(chan *openAPIDoc)
+	go func() {
+		doc, _ := schemas.get(k8sschema.GroupVersionKind{Group: "slow.example.com", Version: "v1", Kind: "Slow"})
+		slow <- doc
+	}()
+
+	// other group versions don't wait for the slow document
+	fast := make(chan *openAPIDoc)
+	go func() {
+		doc, _ := schemas.get(k8sschema.GroupVersionKind{Group: "fast.example.com", Version: "v1", Kind: "Fast"})
+		fast <- doc
+	}()
+
+	select {
+	case doc := <-fast:
+		assert.NotEqual(t, nil, doc)
+	case <-time.After(5 * time.Second):
+		t.Fatal("get blocked by a concurrent fetch")
+	}
+
+	close(unblock)
+	assert.NotEqual(t, nil, <-slow)
+}
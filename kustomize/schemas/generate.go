@@ -0,0 +1,107 @@
+//go:build ignore
+
+// generate bundles the schemas of a Kubernetes version for the
+// kustomization_validate data source. It reads the OpenAPI v2 document
+// of a cluster, or api/openapi-spec/swagger.json of the Kubernetes
+// repository, and writes the definitions, without descriptions, gzipped.
+//
+// Usage:
+//
+//	kubectl get --raw /openapi/v2 > swagger.json
+//	go run kustomize/schemas/generate.go swagger.json kustomize/schemas/v1.31.json.gz
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const quantityDefinition = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: generate <swagger.json> <output.json.gz>")
+		os.Exit(1)
+	}
+
+	if err := generate(os.Args[1], os.Args[2]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generate(in string, out string) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	doc := struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %s", in, err)
+	}
+
+	if len(doc.Definitions) == 0 {
+		return fmt.Errorf("%s: no definitions, not an OpenAPI v2 document", in)
+	}
+
+	for name, d := range doc.Definitions {
+		// quantities are strings in OpenAPI v2, but accept numbers too
+		if name == quantityDefinition {
+			doc.Definitions[name] = map[string]interface{}{"x-kubernetes-int-or-string": true}
+			continue
+		}
+		strip(d)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(zw).Encode(doc); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// strip removes descriptions, and converts the int-or-string
+// format of OpenAPI v2 to the extension OpenAPI v3 uses
+func strip(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		delete(t, "description")
+
+		if t["format"] == "int-or-string" {
+			delete(t, "type")
+			delete(t, "format")
+			t["x-kubernetes-int-or-string"] = true
+		}
+
+		for k, c := range t {
+			// keep properties named description
+			if k == "properties" {
+				for _, p := range c.(map[string]interface{}) {
+					strip(p)
+				}
+				continue
+			}
+			strip(c)
+		}
+	case []interface{}:
+		for _, c := range t {
+			strip(c)
+		}
+	}
+}
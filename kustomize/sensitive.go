@@ -0,0 +1,183 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const sensitiveHashPrefix = "sha256:"
+
+// fields that are always considered sensitive
+var defaultSensitiveFields = []string{
+	"_/Secret:data",
+	"_/Secret:stringData",
+}
+
+type sensitiveField struct {
+	group string
+	kind  string
+	path  []string
+}
+
+func parseSensitiveField(str string) (sf sensitiveField, err error) {
+	parts := strings.SplitN(str, ":", 2)
+	gk := strings.Split(parts[0], "/")
+
+	if len(parts) != 2 || len(gk) != 2 || gk[1] == "" || parts[1] == "" {
+		return sf, fmt.Errorf("invalid sensitive field: %q, valid sensitive fields look like: \"_/Secret:data\"", str)
+	}
+
+	return sensitiveField{
+		group: underscoreToEmpty(gk[0]),
+		kind:  gk[1],
+		path:  strings.Split(parts[1], "."),
+	}, nil
+}
+
+func validateSensitiveField(v interface{}, k string) (ws []string, es []error) {
+	_, err := parseSensitiveField(v.(string))
+	if err != nil {
+		es = append(es, fmt.Errorf("%s: %s", k, err))
+	}
+	return ws, es
+}
+
+// getSensitiveFields returns the default sensitive fields
+// followed by the user configured ones
+func getSensitiveFields(configured []interface{}) (sfs []sensitiveField, err error) {
+	fields := append([]string{}, defaultSensitiveFields...)
+	fields = append(fields, convertListInterfaceToListString(configured)...)
+
+	for _, f := range fields {
+		sf, err := parseSensitiveField(f)
+		if err != nil {
+			return nil, err
+		}
+		sfs = append(sfs, sf)
+	}
+
+	return sfs, nil
+}
+
+func (sf sensitiveField) matches(u *k8sunstructured.Unstructured) bool {
+	return sf.group == u.GroupVersionKind().Group && sf.kind == u.GetKind()
+}
+
+// hasSensitiveFields returns true if any of sfs is set on u
+func hasSensitiveFields(u *k8sunstructured.Unstructured, sfs []sensitiveField) bool {
+	for _, sf := range sfs {
+		if !sf.matches(u) {
+			continue
+		}
+
+		if _, ok, _ := k8sunstructured.NestedFieldNoCopy(u.Object, sf.path...); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashSensitiveValue(v interface{}) string {
+	var b []byte
+	if s, ok := v.(string); ok {
+		b = []byte(s)
+	} else {
+		b, _ = json.Marshal(v)
+	}
+
+	h := sha256.Sum256(b)
+
+	return sensitiveHashPrefix + hex.EncodeToString(h[:])
+}
+
+// normalizeSecretData moves stringData into data, the same
+// way the API server does, so both can be compared to live objects
+func normalizeSecretData(u *k8sunstructured.Unstructured) {
+	if u.GroupVersionKind().Group != "" || u.GetKind() != "Secret" {
+		return
+	}
+
+	stringData, ok, _ := k8sunstructured.NestedMap(u.Object, "stringData")
+	if !ok {
+		return
+	}
+
+	data, _, _ := k8sunstructured.NestedMap(u.Object, "data")
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+
+	for k, v := range stringData {
+		s, _ := v.(string)
+		data[k] = base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	k8sunstructured.SetNestedMap(u.Object, data, "data")
+	k8sunstructured.RemoveNestedField(u.Object, "stringData")
+}
+
+// hashSensitiveFields replaces the values of all sensitive fields in
+// manifest with their hashes. Values are taken from live, if set,
+// so changes made outside of Terraform result in a different hash.
+func hashSensitiveFields(manifest string, live *k8sunstructured.Unstructured, sfs []sensitiveField) (string, error) {
+	km := &kManifest{}
+	err := km.load([]byte(manifest))
+	if err != nil {
+		return "", err
+	}
+	u := km.resource
+
+	src := u
+	if live != nil {
+		src = live.DeepCopy()
+	}
+
+	normalizeSecretData(u)
+	normalizeSecretData(src)
+
+	for _, sf := range sfs {
+		if !sf.matches(u) {
+			continue
+		}
+
+		if _, ok, _ := k8sunstructured.NestedFieldNoCopy(u.Object, sf.path...); !ok {
+			continue
+		}
+
+		v, ok, _ := k8sunstructured.NestedFieldNoCopy(src.Object, sf.path...)
+		if !ok {
+			k8sunstructured.RemoveNestedField(u.Object, sf.path...)
+			continue
+		}
+
+		var hashed interface{}
+		if m, isMap := v.(map[string]interface{}); isMap {
+			hm := make(map[string]interface{})
+			for k, mv := range m {
+				hm[k] = hashSensitiveValue(mv)
+			}
+			hashed = hm
+		} else {
+			hashed = hashSensitiveValue(v)
+		}
+
+		err = k8sunstructured.SetNestedField(u.Object, hashed, sf.path...)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	b, err := u.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
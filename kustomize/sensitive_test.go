@@ -0,0 +1,128 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const sensitiveTestSecretData = `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test"},"data":{"password":"c2VjcmV0"}}`
+const sensitiveTestSecretStringData = `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test"},"stringData":{"password":"secret"}}`
+
+func TestParseSensitiveField(t *testing.T) {
+	sf, err := parseSensitiveField("_/ConfigMap:data")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sensitiveField{group: "", kind: "ConfigMap", path: []string{"data"}}, sf)
+
+	sf, err = parseSensitiveField("apps/Deployment:spec.template.spec.containers")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sensitiveField{group: "apps", kind: "Deployment", path: []string{"spec", "template", "spec", "containers"}}, sf)
+
+	for _, invalid := range []string{"ConfigMap:data", "_/ConfigMap", "_/ConfigMap:", "_/:data"} {
+		_, err = parseSensitiveField(invalid)
+		assert.NotEqual(t, nil, err, invalid)
+	}
+}
+
+func TestHasSensitiveFields(t *testing.T) {
+	sfs, err := getSensitiveFields([]interface{}{"_/ConfigMap:data"})
+	assert.Equal(t, nil, err)
+
+	for manifest, expected := range map[string]bool{
+		sensitiveTestSecretData:       true,
+		sensitiveTestSecretStringData: true,
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test"},"data":{"key":"value"}}`: true,
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test"}}`:                        false,
+		`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`:                        false,
+	} {
+		km := &kManifest{}
+		err := km.load([]byte(manifest))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, hasSensitiveFields(km.resource, sfs), manifest)
+	}
+}
+
+func TestHashSensitiveFields(t *testing.T) {
+	sfs, err := getSensitiveFields(nil)
+	assert.Equal(t, nil, err)
+
+	hashed, err := hashSensitiveFields(sensitiveTestSecretData, nil, sfs)
+	assert.Equal(t, nil, err)
+	assert.NotContains(t, hashed, "c2VjcmV0")
+	assert.Contains(t, hashed, sensitiveHashPrefix)
+
+	// stringData and data with the same value have the same hash
+	hashedStringData, err := hashSensitiveFields(sensitiveTestSecretStringData, nil, sfs)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, hashed, hashedStringData)
+
+	// values are taken from the live object if provided
+	live := &kManifest{}
+	err = live.load([]byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test"},"data":{"password":"Y2hhbmdlZA=="}}`))
+	assert.Equal(t, nil, err)
+
+	hashedLive, err := hashSensitiveFields(sensitiveTestSecretData, live.resource, sfs)
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, hashed, hashedLive)
+}
+
+func TestSuppressHashedManifestDiff(t *testing.T) {
+	sfs, err := getSensitiveFields(nil)
+	assert.Equal(t, nil, err)
+
+	hashed, err := hashSensitiveFields(sensitiveTestSecretData, nil, sfs)
+	assert.Equal(t, nil, err)
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest":              sensitiveTestSecretData,
+		"hash_sensitive_fields": true,
+	})
+	assert.True(t, suppressManifestDiff("manifest", hashed, sensitiveTestSecretData, d))
+	assert.True(t, suppressManifestDiff("manifest", hashed, sensitiveTestSecretStringData, d))
+	assert.False(t, suppressManifestDiff("manifest", hashed, `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test"},"data":{"password":"b3RoZXI="}}`, d))
+
+	d = schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": sensitiveTestSecretData,
+	})
+	assert.False(t, suppressManifestDiff("manifest", hashed, sensitiveTestSecretData, d))
+}
+
+func TestSetManifestHashedYAML(t *testing.T) {
+	live := &kManifest{}
+	err := live.load([]byte(sensitiveTestSecretData))
+	assert.Equal(t, nil, err)
+	setLastAppliedConfig(live, false)
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest":              sensitiveTestSecretData,
+		"hash_sensitive_fields": true,
+	})
+	err = setManifest(d, live.resource, false)
+	assert.Equal(t, nil, err)
+
+	manifestYAML := d.Get("manifest_yaml").(string)
+	assert.NotContains(t, manifestYAML, "c2VjcmV0")
+	assert.Contains(t, manifestYAML, "  password: "+sensitiveHashPrefix)
+	assert.Contains(t, manifestYAML, "kind: Secret\n")
+}
+
+func TestSetManifestUnhashedYAML(t *testing.T) {
+	live := &kManifest{}
+	err := live.load([]byte(sensitiveTestSecretData))
+	assert.Equal(t, nil, err)
+	setLastAppliedConfig(live, false)
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": sensitiveTestSecretData,
+	})
+	err = setManifest(d, live.resource, false)
+	assert.Equal(t, nil, err)
+
+	// the manifest keeps the values, manifest_yaml is shown in plans
+	assert.Contains(t, d.Get("manifest").(string), "c2VjcmV0")
+
+	manifestYAML := d.Get("manifest_yaml").(string)
+	assert.NotContains(t, manifestYAML, "c2VjcmV0")
+	assert.Contains(t, manifestYAML, "  password: "+sensitiveHashPrefix)
+}
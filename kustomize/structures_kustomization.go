@@ -1,7 +1,10 @@
 package kustomize
 
 import (
+	"sort"
+
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 func flattenKustomizationIDs(rm resmap.ResMap) (ids []string, idsPrio [][]string, err error) {
@@ -35,6 +38,43 @@ func flattenKustomizationIDs(rm resmap.ResMap) (ids []string, idsPrio [][]string
 	return ids, idsPrio, nil
 }
 
+func flattenKustomizationIDsOrdered(rm resmap.ResMap) (ids []string, err error) {
+	type orderedID struct {
+		id       string
+		priority int
+	}
+
+	ordered := []orderedID{}
+	for _, r := range rm.Resources() {
+		kr := &kManifestId{
+			group:     r.CurId().Group,
+			kind:      r.CurId().Kind,
+			namespace: r.GetNamespace(),
+			name:      r.GetName(),
+		}
+
+		p, err := determinePriority(kr, r.GetAnnotations())
+		if err != nil {
+			return nil, err
+		}
+
+		ordered = append(ordered, orderedID{kr.string(), p})
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].priority != ordered[j].priority {
+			return ordered[i].priority < ordered[j].priority
+		}
+		return ordered[i].id < ordered[j].id
+	})
+
+	for _, o := range ordered {
+		ids = append(ids, o.id)
+	}
+
+	return ids, nil
+}
+
 func flattenKustomizationResources(rm resmap.ResMap) (res map[string]string, err error) {
 	res = make(map[string]string)
 	for _, r := range rm.Resources() {
@@ -53,3 +93,64 @@ func flattenKustomizationResources(rm resmap.ResMap) (res map[string]string, err
 	}
 	return res, nil
 }
+
+func flattenKustomizationSensitiveResources(rm resmap.ResMap, resources map[string]string, sfs []sensitiveField, selectors []types.Selector) (res map[string]string, err error) {
+	res = make(map[string]string)
+	for id, manifest := range resources {
+		km := &kManifest{}
+		err := km.load([]byte(manifest))
+		if err != nil {
+			return nil, err
+		}
+
+		if hasSensitiveFields(km.resource, sfs) {
+			res[id] = manifest
+		}
+	}
+
+	for _, s := range selectors {
+		selected, err := rm.Select(s)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range selected {
+			kr := &kManifestId{
+				group:     r.CurId().Group,
+				kind:      r.CurId().Kind,
+				namespace: r.GetNamespace(),
+				name:      r.GetName(),
+			}
+			res[kr.string()] = resources[kr.string()]
+		}
+	}
+
+	return res, nil
+}
+
+func flattenKustomizationDependencies(resources map[string]string) (deps []interface{}, err error) {
+	graph, err := dependencyGraph(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		dependsOn := []interface{}{}
+		for _, dep := range graph[id] {
+			dependsOn = append(dependsOn, dep)
+		}
+
+		deps = append(deps, map[string]interface{}{
+			"id":         id,
+			"depends_on": dependsOn,
+		})
+	}
+
+	return deps, nil
+}
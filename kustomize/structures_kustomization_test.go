@@ -5,7 +5,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 )
 
 func TestConvertKustomizationIDs(t *testing.T) {
@@ -35,3 +37,127 @@ func TestConvertKustomizationIDs(t *testing.T) {
 	expP3 := []string{}
 	assert.ElementsMatch(t, expP3, idsPrio[2], nil)
 }
+
+func TestFlattenKustomizationIDsOrdered(t *testing.T) {
+	fSys := filesys.MakeFsOnDisk()
+	opts := krusty.MakeDefaultOptions()
+	k := krusty.MakeKustomizer(opts)
+
+	rm, err := k.Run(fSys, "test_kustomizations/basic/initial")
+	assert.Equal(t, err, nil, nil)
+
+	ids, err := flattenKustomizationIDsOrdered(rm)
+	assert.Equal(t, err, nil, nil)
+
+	expIds := []string{"_/Namespace/_/test-basic", "_/Service/test-basic/test", "apps/Deployment/test-basic/test", "networking.k8s.io/Ingress/test-basic/test"}
+	assert.Equal(t, expIds, ids, nil)
+}
+
+func TestFlattenKustomizationIDsOrderedAnnotation(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("kustomization.yaml", []byte(`
+resources:
+- resources.yaml
+`))
+	fSys.WriteFile("resources.yaml", []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: test
+---
+apiVersion: scheduling.k8s.io/v1
+kind: PriorityClass
+metadata:
+  name: test
+  annotations:
+    kustomization.kubestack.com/apply-priority: "0"
+`))
+
+	opts := krusty.MakeDefaultOptions()
+	k := krusty.MakeKustomizer(opts)
+
+	rm, err := k.Run(fSys, ".")
+	assert.Equal(t, err, nil, nil)
+
+	ids, err := flattenKustomizationIDsOrdered(rm)
+	assert.Equal(t, err, nil, nil)
+
+	expIds := []string{"scheduling.k8s.io/PriorityClass/_/test", "_/Namespace/_/test", "_/ConfigMap/test/test"}
+	assert.Equal(t, expIds, ids, nil)
+}
+
+func TestFlattenKustomizationSensitiveResources(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("kustomization.yaml", []byte(`
+resources:
+- resources.yaml
+`))
+	fSys.WriteFile("resources.yaml", []byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+  namespace: test
+stringData:
+  password: secret
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: test
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: credentials
+  namespace: test
+data:
+  key: value
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: test
+  namespace: test
+`))
+
+	opts := krusty.MakeDefaultOptions()
+	k := krusty.MakeKustomizer(opts)
+
+	rm, err := k.Run(fSys, ".")
+	assert.Equal(t, err, nil, nil)
+
+	resources, err := flattenKustomizationResources(rm)
+	assert.Equal(t, err, nil, nil)
+
+	sfs, err := getSensitiveFields(nil)
+	assert.Equal(t, err, nil, nil)
+
+	res, err := flattenKustomizationSensitiveResources(rm, resources, sfs, nil)
+	assert.Equal(t, err, nil, nil)
+	assert.Equal(t, []string{"_/Secret/test/test"}, mapKeys(res), nil)
+
+	selectors := []types.Selector{
+		{ResId: resid.ResId{Gvk: resid.Gvk{Kind: "ConfigMap"}, Name: "cred.*"}},
+	}
+	res, err = flattenKustomizationSensitiveResources(rm, resources, sfs, selectors)
+	assert.Equal(t, err, nil, nil)
+	assert.ElementsMatch(t, []string{"_/Secret/test/test", "_/ConfigMap/test/credentials"}, mapKeys(res), nil)
+	assert.Equal(t, resources["_/ConfigMap/test/credentials"], res["_/ConfigMap/test/credentials"], nil)
+}
+
+func mapKeys(m map[string]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,118 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// waits shorter than this are not logged
+const throttleLogThreshold = 100 * time.Millisecond
+
+// loggingRateLimiter logs when the client side rate limit
+// delays a request, so throttling is visible in TF_LOG output
+type loggingRateLimiter struct {
+	flowcontrol.RateLimiter
+}
+
+func newLoggingRateLimiter(qps float32, burst int) *loggingRateLimiter {
+	return &loggingRateLimiter{flowcontrol.NewTokenBucketRateLimiter(qps, burst)}
+}
+
+func (rl *loggingRateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := rl.RateLimiter.Wait(ctx)
+
+	if waited := time.Since(start); waited > throttleLogThreshold {
+		log.Printf("[DEBUG] client-side throttling: request waited %s for qps %v and burst limit", waited, rl.QPS())
+	}
+
+	return err
+}
+
+// concurrencyLimiter limits the number of in-flight requests
+// shared by all clients created from the same rest config
+type concurrencyLimiter struct {
+	rt  http.RoundTripper
+	sem chan struct{}
+}
+
+func newConcurrencyLimiter(max int) func(http.RoundTripper) http.RoundTripper {
+	sem := make(chan struct{}, max)
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &concurrencyLimiter{rt: rt, sem: sem}
+	}
+}
+
+func (cl *concurrencyLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	select {
+	case cl.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	defer func() { <-cl.sem }()
+
+	if waited := time.Since(start); waited > throttleLogThreshold {
+		log.Printf("[DEBUG] max_concurrent_requests: %s %s waited %s for one of %d request slots", req.Method, req.URL.Path, waited, cap(cl.sem))
+	}
+
+	resp, err := cl.rt.RoundTrip(req)
+	if err == nil {
+		logServerThrottling(req, resp)
+	}
+
+	return resp, err
+}
+
+// logServerThrottling logs requests rejected by
+// API Priority and Fairness on the server side
+func logServerThrottling(req *http.Request, resp *http.Response) {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	log.Printf("[DEBUG] server-side throttling: %s %s rejected, retry after %q, priority level %q",
+		req.Method,
+		req.URL.Path,
+		resp.Header.Get("Retry-After"),
+		resp.Header.Get("X-Kubernetes-Pf-Prioritylevel-Uid"))
+}
+
+// serverThrottlingLogger logs server side throttling
+// when max_concurrent_requests is not set
+type serverThrottlingLogger struct {
+	rt http.RoundTripper
+}
+
+func (stl *serverThrottlingLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := stl.rt.RoundTrip(req)
+	if err == nil {
+		logServerThrottling(req, resp)
+	}
+
+	return resp, err
+}
+
+func validateDuration(v interface{}, k string) (ws []string, es []error) {
+	s := v.(string)
+	if s == "" {
+		return ws, es
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		es = append(es, fmt.Errorf("%s: invalid duration %q: %s", k, s, err))
+		return ws, es
+	}
+
+	if d < 0 {
+		es = append(es, fmt.Errorf("%s: duration must not be negative, got %q", k, s))
+	}
+
+	return ws, es
+}
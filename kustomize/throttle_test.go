@@ -0,0 +1,59 @@
+package kustomize
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newConcurrencyLimiter(2)(http.DefaultTransport)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			assert.Equal(t, nil, err)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxInFlight, int32(2))
+	assert.Greater(t, maxInFlight, int32(0))
+}
+
+func TestValidateDuration(t *testing.T) {
+	for _, valid := range []string{"", "0", "30s", "1m30s"} {
+		_, es := validateDuration(valid, "request_timeout")
+		assert.Equal(t, 0, len(es), valid)
+	}
+
+	for _, invalid := range []string{"30", "soon", "-1s"} {
+		_, es := validateDuration(invalid, "request_timeout")
+		assert.Equal(t, 1, len(es), invalid)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/mergepatch"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/yaml"
 )
 
 const lastAppliedConfigAnnotation = k8scorev1.LastAppliedConfigAnnotation
@@ -93,6 +94,37 @@ func getLastAppliedConfig(u *k8sunstructured.Unstructured, gzipLastAppliedConfig
 	return strings.TrimRight(lac, "\r\n")
 }
 
+// migrateLastAppliedConfig sets the apiVersion of the lastAppliedConfig
+// of u to apiVersion, e.g. after reading u through a different version
+func migrateLastAppliedConfig(u *k8sunstructured.Unstructured, apiVersion string, gzipLastAppliedConfig bool) error {
+	lac := getLastAppliedConfig(u, gzipLastAppliedConfig)
+	if lac == "" {
+		return nil
+	}
+
+	km := &kManifest{}
+	if err := km.load([]byte(lac)); err != nil {
+		return err
+	}
+
+	km.resource.SetAPIVersion(apiVersion)
+	json, err := km.resource.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("json error: %s", err)
+	}
+
+	// set on u, after removing both the plain and the compressed
+	// annotation, so the previous one can't take precedence
+	annotations := u.GetAnnotations()
+	delete(annotations, lastAppliedConfigAnnotation)
+	delete(annotations, gzipLastAppliedConfigAnnotation)
+	u.SetAnnotations(annotations)
+
+	setLastAppliedConfig(&kManifest{resource: u, json: json}, gzipLastAppliedConfig)
+
+	return nil
+}
+
 func getPatch(gvk k8sschema.GroupVersionKind, original []byte, modified []byte, current []byte) (pt k8stypes.PatchType, p []byte, err error) {
 	versionedObject, err := scheme.Scheme.New(gvk)
 	switch {
@@ -127,6 +159,26 @@ func getPatch(gvk k8sschema.GroupVersionKind, original []byte, modified []byte,
 	return pt, p, nil
 }
 
+// getManifestYAML returns manifest as YAML with sorted keys, so
+// changes show line by line in the plan, instead of as one JSON string
+func getManifestYAML(manifest string) (string, error) {
+	if manifest == "" {
+		return "", nil
+	}
+
+	km := &kManifest{}
+	if err := km.load([]byte(manifest)); err != nil {
+		return "", err
+	}
+
+	y, err := yaml.Marshal(km.resource.Object)
+	if err != nil {
+		return "", fmt.Errorf("yaml error: %s", err)
+	}
+
+	return string(y), nil
+}
+
 // log error including caller name
 func logError(m error) error {
 	pc, _, _, _ := runtime.Caller(1)
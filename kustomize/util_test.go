@@ -30,6 +30,27 @@ func TestLastAppliedConfig(t *testing.T) {
 	}
 }
 
+func TestMigrateLastAppliedConfig(t *testing.T) {
+	for _, size := range []int{0, 256 * (1 << 10)} {
+		srcJSON := fmt.Sprintf("{\"apiVersion\": \"batch/v1beta1\", \"kind\": \"CronJob\", \"metadata\": {\"name\": \"test-unit\", \"namespace\": \"test-unit\", \"annotations\": {\"filler\": %q}}}", randomDataHelper(size))
+
+		km := &kManifest{}
+		err := km.load([]byte(srcJSON))
+		assert.Equal(t, nil, err)
+		setLastAppliedConfig(km, true)
+
+		err = migrateLastAppliedConfig(km.resource, "batch/v1", true)
+		assert.Equal(t, nil, err)
+
+		migrated := &kManifest{}
+		err = migrated.load([]byte(getLastAppliedConfig(km.resource, true)))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "batch/v1", migrated.resource.GetAPIVersion())
+		assert.Equal(t, "test-unit", migrated.resource.GetName())
+		assert.Equal(t, 2, len(km.resource.GetAnnotations()))
+	}
+}
+
 func randomDataHelper(n int) string {
 	const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	b := make([]byte, n)
@@ -147,3 +168,24 @@ func TestGetPatchMergePatch(t *testing.T) {
 	assert.Equal(t, `{"metadata":{"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"apiVersion\":\"test.example.com/v1alpha1\",\"kind\":\"Namespacedcrd\",\"metadata\":{\"name\":\"namespacedco\",\"namespace\":\"test-crd\"},\"spec\":{\"test-key\":\"test-value\"}}\n"}},"spec":{"test-key":"test-value"}}`, string(p), nil)
 	assert.Equal(t, types.MergePatchType, pt, nil)
 }
+
+func TestGetManifestYAML(t *testing.T) {
+	y, err := getManifestYAML(`{"kind": "ConfigMap", "apiVersion": "v1", "metadata": {"name": "test", "namespace": "test"}, "data": {"b": "2", "a": "1"}}`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  a: "1"
+  b: "2"
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: test
+`, y)
+
+	y, err = getManifestYAML("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", y)
+
+	_, err = getManifestYAML("{")
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,133 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	k8snet "k8s.io/apimachinery/pkg/util/net"
+)
+
+type warningContextKey int
+
+const (
+	warningCollectorKey warningContextKey = iota
+	warningSourceKey
+)
+
+// warningLogger replaces the default warning handler of client-go,
+// that prints to stderr where nobody sees it, with the provider log.
+// Warnings are returned as diagnostics by the warningCollector.
+type warningLogger struct{}
+
+func (warningLogger) HandleWarningHeader(code int, agent string, text string) {
+	if code != 299 || text == "" {
+		return
+	}
+
+	log.Printf("[WARN] API server warning: %s", text)
+}
+
+// warningCollector collects the API server warnings of one operation,
+// e.g. a create, by the object that caused them
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []apiWarning
+	seen     map[apiWarning]bool
+}
+
+type apiWarning struct {
+	source string
+	text   string
+}
+
+// withWarningCollector returns ctx with a new warningCollector for
+// the warnings of all requests made using the returned context
+func withWarningCollector(ctx context.Context) (context.Context, *warningCollector) {
+	wc := &warningCollector{seen: make(map[apiWarning]bool)}
+	return context.WithValue(ctx, warningCollectorKey, wc), wc
+}
+
+// withWarningSource returns ctx with source, e.g. the id of the object
+// of the kManifest, to attribute warnings of requests to
+func withWarningSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, warningSourceKey, source)
+}
+
+func (wc *warningCollector) add(source string, text string) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	w := apiWarning{source: source, text: text}
+	if wc.seen[w] {
+		return
+	}
+
+	wc.seen[w] = true
+	wc.warnings = append(wc.warnings, w)
+}
+
+func (wc *warningCollector) diagnostics() (diags diag.Diagnostics) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	for _, w := range wc.warnings {
+		summary := "API server warning"
+		if w.source != "" {
+			summary = fmt.Sprintf("API server warning for %q", w.source)
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  summary,
+			Detail:   w.text,
+		})
+	}
+
+	return diags
+}
+
+// warningTransport passes the warning headers of responses
+// to the warningCollector of the request's context, if any
+type warningTransport struct {
+	rt http.RoundTripper
+}
+
+func (wt *warningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := wt.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	wc, ok := req.Context().Value(warningCollectorKey).(*warningCollector)
+	if !ok {
+		return resp, err
+	}
+
+	source, _ := req.Context().Value(warningSourceKey).(string)
+
+	// malformed headers are ignored, like client-go does
+	warnings, _ := k8snet.ParseWarningHeaders(resp.Header["Warning"])
+	for _, w := range warnings {
+		if w.Code == 299 && w.Text != "" {
+			wc.add(source, w.Text)
+		}
+	}
+
+	return resp, err
+}
+
+// collectWarnings wraps fn to return the API server warnings
+// of all requests made during fn as warning diagnostics
+func collectWarnings(fn func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		ctx, wc := withWarningCollector(ctx)
+		diags := fn(ctx, d, m)
+
+		return append(diags, wc.diagnostics()...)
+	}
+}
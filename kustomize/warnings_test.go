@@ -0,0 +1,78 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const testDeprecationWarning = "policy/v1beta1 PodSecurityPolicy is deprecated in v1.21+, unavailable in v1.25+"
+
+func testWarningClient(t *testing.T) dynamic.Interface {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Warning", fmt.Sprintf("299 - %q", testDeprecationWarning))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	config := &rest.Config{Host: srv.URL, WarningHandler: warningLogger{}}
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &warningTransport{rt}
+	})
+
+	client, err := dynamic.NewForConfig(config)
+	assert.Equal(t, nil, err)
+
+	return client
+}
+
+func TestWarningCollector(t *testing.T) {
+	client := testWarningClient(t)
+	api := client.Resource(testConfigMapGVR).Namespace("test")
+
+	ctx, wc := withWarningCollector(context.Background())
+	ctx = withWarningSource(ctx, "_/ConfigMap/test/test")
+
+	// the same warning is only reported once per source
+	for i := 0; i < 2; i++ {
+		_, err := api.Get(ctx, "test", k8smetav1.GetOptions{})
+		assert.Equal(t, nil, err)
+	}
+
+	diags := wc.diagnostics()
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, diag.Warning, diags[0].Severity)
+	assert.Equal(t, `API server warning for "_/ConfigMap/test/test"`, diags[0].Summary)
+	assert.Equal(t, testDeprecationWarning, diags[0].Detail)
+
+	// requests without a collector are only logged
+	_, err := api.Get(context.Background(), "test", k8smetav1.GetOptions{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(wc.diagnostics()))
+}
+
+func TestCollectWarnings(t *testing.T) {
+	client := testWarningClient(t)
+	api := client.Resource(testConfigMapGVR).Namespace("test")
+
+	fn := collectWarnings(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		_, err := api.Get(ctx, "test", k8smetav1.GetOptions{})
+		return diag.FromErr(err)
+	})
+
+	diags := fn(context.Background(), nil, nil)
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, "API server warning", diags[0].Summary)
+	assert.False(t, diags.HasError())
+}